@@ -0,0 +1,16 @@
+// Package backend 提供 core.Backend 的内置实现。
+//
+//   - Etcd: 基于 etcd v3 客户端，engine.New 的默认后端
+//   - Memory: 纯内存实现，保留版本、前缀监听、压缩与租约语义，用于单元测试
+//
+// 使用示例：
+//
+//	mem := backend.NewMemory()
+//	eng := engine.NewEngineWithBackend(mem, &engine.Config{
+//	    Configs: []core.WatchConfig{store.Bind[DatabaseConfig]("/app/config/database/")},
+//	})
+//	defer eng.Close(context.Background())
+//
+//	// 模拟压缩，验证监听自愈
+//	_ = mem.Compact(mem.Revision())
+package backend
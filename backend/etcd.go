@@ -0,0 +1,231 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Etcd 基于 etcd v3 客户端的存储后端
+type Etcd struct {
+	client *clientv3.Client
+}
+
+// NewEtcd 创建 etcd 存储后端
+// 参数：
+//   - client: etcd 客户端（由调用方管理生命周期）
+//
+// 返回：
+//   - *Etcd: 存储后端
+func NewEtcd(client *clientv3.Client) *Etcd {
+	return &Etcd{client: client}
+}
+
+// Client 返回底层的 etcd 客户端
+func (b *Etcd) Client() *clientv3.Client {
+	return b.client
+}
+
+// Get 读取键或前缀
+func (b *Etcd) Get(ctx context.Context, key string, prefix bool) (*core.GetResult, error) {
+	var opts []clientv3.OpOption
+	if prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+
+	resp, err := b.client.Get(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &core.GetResult{
+		Revision: resp.Header.Revision,
+		Kvs:      make([]*core.KeyValue, 0, len(resp.Kvs)),
+	}
+	for _, kv := range resp.Kvs {
+		result.Kvs = append(result.Kvs, toKeyValue(kv))
+	}
+	return result, nil
+}
+
+// Put 写入键
+func (b *Etcd) Put(ctx context.Context, key string, value []byte, lease int64) (int64, error) {
+	resp, err := b.client.Put(ctx, key, string(value), clientv3.WithLease(clientv3.LeaseID(lease)))
+	if err != nil {
+		return 0, err
+	}
+	return resp.Header.Revision, nil
+}
+
+// Delete 删除键或前缀
+func (b *Etcd) Delete(ctx context.Context, key string, prefix bool) (int64, error) {
+	var opts []clientv3.OpOption
+	if prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+
+	resp, err := b.client.Delete(ctx, key, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Header.Revision, nil
+}
+
+// Txn 执行事务
+func (b *Etcd) Txn(ctx context.Context, txn *core.Txn) (*core.TxnResult, error) {
+	cmps := make([]clientv3.Cmp, 0, len(txn.If))
+	for _, cmp := range txn.If {
+		converted, err := toCmp(cmp)
+		if err != nil {
+			return nil, err
+		}
+		cmps = append(cmps, converted)
+	}
+
+	resp, err := b.client.Txn(ctx).If(cmps...).Then(toOps(txn.Then)...).Else(toOps(txn.Else)...).Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &core.TxnResult{Succeeded: resp.Succeeded, Revision: resp.Header.Revision}, nil
+}
+
+// Watch 监听键或前缀
+// 说明：
+//   - 附加 WithRequireLeader，失去 leader 时通道关闭
+func (b *Etcd) Watch(ctx context.Context, key string, req core.WatchRequest) <-chan *core.WatchResponse {
+	ctx = clientv3.WithRequireLeader(ctx)
+
+	opts := []clientv3.OpOption{clientv3.WithCreatedNotify()}
+	if req.Prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	if req.Revision > 0 {
+		opts = append(opts, clientv3.WithRev(req.Revision))
+	}
+	if req.PrevKV {
+		opts = append(opts, clientv3.WithPrevKV())
+	}
+
+	out := make(chan *core.WatchResponse)
+	go func() {
+		defer close(out)
+		for watchResp := range b.client.Watch(ctx, key, opts...) {
+			select {
+			case out <- toWatchResponse(watchResp):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// RequestProgress 请求监听进度
+func (b *Etcd) RequestProgress(ctx context.Context) error {
+	return b.client.RequestProgress(clientv3.WithRequireLeader(ctx))
+}
+
+// Grant 创建租约
+func (b *Etcd) Grant(ctx context.Context, ttl int64) (int64, error) {
+	resp, err := b.client.Grant(ctx, ttl)
+	if err != nil {
+		return 0, err
+	}
+	return int64(resp.ID), nil
+}
+
+// KeepAliveOnce 续约一次
+func (b *Etcd) KeepAliveOnce(ctx context.Context, lease int64) (int64, error) {
+	resp, err := b.client.KeepAliveOnce(ctx, clientv3.LeaseID(lease))
+	if err != nil {
+		return 0, err
+	}
+	return resp.TTL, nil
+}
+
+// Revoke 撤销租约
+func (b *Etcd) Revoke(ctx context.Context, lease int64) error {
+	_, err := b.client.Revoke(ctx, clientv3.LeaseID(lease))
+	return err
+}
+
+// toKeyValue 转换 etcd 键值
+func toKeyValue(kv *mvccpb.KeyValue) *core.KeyValue {
+	if kv == nil {
+		return nil
+	}
+
+	return &core.KeyValue{
+		Key:            string(kv.Key),
+		Value:          kv.Value,
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Version:        kv.Version,
+		Lease:          kv.Lease,
+	}
+}
+
+// toWatchResponse 转换 etcd 监听响应
+func toWatchResponse(watchResp clientv3.WatchResponse) *core.WatchResponse {
+	resp := &core.WatchResponse{
+		Revision:        watchResp.Header.Revision,
+		Created:         watchResp.Created,
+		Progress:        watchResp.IsProgressNotify(),
+		CompactRevision: watchResp.CompactRevision,
+		Events:          make([]*core.KvEvent, 0, len(watchResp.Events)),
+	}
+	if resp.CompactRevision == 0 {
+		resp.Err = watchResp.Err()
+	}
+
+	for _, ev := range watchResp.Events {
+		event := &core.KvEvent{
+			Type:   core.EventTypePut,
+			Kv:     toKeyValue(ev.Kv),
+			PrevKv: toKeyValue(ev.PrevKv),
+		}
+		if ev.Type == clientv3.EventTypeDelete {
+			event.Type = core.EventTypeDelete
+		}
+		resp.Events = append(resp.Events, event)
+	}
+	return resp
+}
+
+// toCmp 转换事务条件
+func toCmp(cmp core.Compare) (clientv3.Cmp, error) {
+	result := string(cmp.Result)
+	switch cmp.Target {
+	case core.CompareValue:
+		return clientv3.Compare(clientv3.Value(cmp.Key), result, string(cmp.Value)), nil
+	case core.CompareVersion:
+		return clientv3.Compare(clientv3.Version(cmp.Key), result, cmp.Revision), nil
+	case core.CompareCreateRevision:
+		return clientv3.Compare(clientv3.CreateRevision(cmp.Key), result, cmp.Revision), nil
+	case core.CompareModRevision:
+		return clientv3.Compare(clientv3.ModRevision(cmp.Key), result, cmp.Revision), nil
+	default:
+		return clientv3.Cmp{}, fmt.Errorf("%w: 未知的比较对象 %d", core.ErrInvalidConfig, cmp.Target)
+	}
+}
+
+// toOps 转换事务操作
+func toOps(ops []core.Op) []clientv3.Op {
+	converted := make([]clientv3.Op, 0, len(ops))
+	for _, op := range ops {
+		switch op.Type {
+		case core.EventTypePut:
+			converted = append(converted, clientv3.OpPut(op.Key, string(op.Value), clientv3.WithLease(clientv3.LeaseID(op.Lease))))
+		case core.EventTypeDelete:
+			if op.Prefix {
+				converted = append(converted, clientv3.OpDelete(op.Key, clientv3.WithPrefix()))
+			} else {
+				converted = append(converted, clientv3.OpDelete(op.Key))
+			}
+		}
+	}
+	return converted
+}
@@ -0,0 +1,581 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// Memory 纯内存存储后端
+// 说明：
+//   - 与 etcd 一致：每次写入（包括事务）版本加一，删除不存在的键不改变版本
+//   - 保留压缩前的变更历史，监听可以从任意未压缩的版本开始回放
+//   - 租约到期或撤销时删除绑定的键，并产生 DELETE 事件
+//   - 进程内使用，不持久化
+type Memory struct {
+	mu        sync.Mutex
+	revision  int64                       // 当前版本
+	compacted int64                       // 已压缩到的版本
+	kvs       map[string]*core.KeyValue   // 当前键值
+	history   []*memoryRevision           // 未压缩的变更历史，按版本递增
+	watchers  map[*memoryWatcher]struct{} // 活跃的监听
+	leases    map[int64]*memoryLease      // 有效的租约
+	leaseSeq  int64                       // 租约 ID 序列
+}
+
+// memoryRevision 单个版本的变更
+type memoryRevision struct {
+	revision int64
+	events   []*core.KvEvent
+}
+
+// memoryLease 内存租约
+type memoryLease struct {
+	ttl   int64
+	keys  map[string]struct{}
+	timer *time.Timer
+}
+
+// NewMemory 创建内存存储后端
+// 返回：
+//   - *Memory: 存储后端，初始版本为 1（与新建的 etcd 集群一致）
+func NewMemory() *Memory {
+	return &Memory{
+		revision: 1,
+		kvs:      make(map[string]*core.KeyValue),
+		watchers: make(map[*memoryWatcher]struct{}),
+		leases:   make(map[int64]*memoryLease),
+	}
+}
+
+// Revision 返回当前版本
+func (m *Memory) Revision() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.revision
+}
+
+// Compact 压缩变更历史
+// 参数：
+//   - revision: 压缩到的版本，早于该版本的历史被丢弃
+//
+// 返回：
+//   - error: 版本已被压缩或晚于当前版本时返回错误
+//
+// 说明：
+//   - 之后从早于 revision 的版本开始的监听会收到 CompactRevision 响应
+func (m *Memory) Compact(revision int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if revision <= m.compacted {
+		return fmt.Errorf("%w: %d", core.ErrCompacted, revision)
+	}
+	if revision > m.revision {
+		return fmt.Errorf("%w: 压缩版本 %d 晚于当前版本 %d", core.ErrInvalidConfig, revision, m.revision)
+	}
+
+	m.compacted = revision
+	index := sort.Search(len(m.history), func(i int) bool {
+		return m.history[i].revision >= revision
+	})
+	m.history = m.history[index:]
+	return nil
+}
+
+// DropWatches 中断所有活跃的监听
+// 说明：
+//   - 模拟连接中断或失去 leader，监听通道在送达已排队的响应后关闭
+func (m *Memory) DropWatches() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for watcher := range m.watchers {
+		delete(m.watchers, watcher)
+		watcher.finish()
+	}
+}
+
+// Get 读取键或前缀
+func (m *Memory) Get(ctx context.Context, key string, prefix bool) (*core.GetResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &core.GetResult{Revision: m.revision}
+	for _, k := range m.matchKeys(key, prefix) {
+		result.Kvs = append(result.Kvs, cloneKeyValue(m.kvs[k]))
+	}
+	return result, nil
+}
+
+// Put 写入键
+func (m *Memory) Put(ctx context.Context, key string, value []byte, lease int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.commit([]core.Op{{Type: core.EventTypePut, Key: key, Value: value, Lease: lease}})
+}
+
+// Delete 删除键或前缀
+func (m *Memory) Delete(ctx context.Context, key string, prefix bool) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.commit([]core.Op{{Type: core.EventTypeDelete, Key: key, Prefix: prefix}})
+}
+
+// Txn 执行事务
+func (m *Memory) Txn(ctx context.Context, txn *core.Txn) (*core.TxnResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	succeeded := true
+	for _, cmp := range txn.If {
+		ok, err := m.compare(cmp)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			succeeded = false
+			break
+		}
+	}
+
+	ops := txn.Then
+	if !succeeded {
+		ops = txn.Else
+	}
+
+	revision, err := m.commit(ops)
+	if err != nil {
+		return nil, err
+	}
+	return &core.TxnResult{Succeeded: succeeded, Revision: revision}, nil
+}
+
+// Watch 监听键或前缀
+func (m *Memory) Watch(ctx context.Context, key string, req core.WatchRequest) <-chan *core.WatchResponse {
+	watcher := newMemoryWatcher(ctx, key, req)
+
+	m.mu.Lock()
+	watcher.enqueue(&core.WatchResponse{Revision: m.revision, Created: true})
+
+	start := req.Revision
+	if start <= 0 {
+		start = m.revision + 1
+	}
+
+	if start < m.compacted {
+		watcher.enqueue(&core.WatchResponse{Revision: m.revision, CompactRevision: m.compacted})
+		watcher.finish()
+		m.mu.Unlock()
+
+		go watcher.run(m)
+		return watcher.out
+	}
+
+	for _, rev := range m.history {
+		if rev.revision >= start {
+			watcher.send(rev)
+		}
+	}
+	m.watchers[watcher] = struct{}{}
+	m.mu.Unlock()
+
+	go watcher.run(m)
+	return watcher.out
+}
+
+// RequestProgress 请求监听进度
+// 说明：
+//   - 变更在写入时即进入监听队列，进度通知排在已产生的变更之后送达
+func (m *Memory) RequestProgress(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for watcher := range m.watchers {
+		if watcher.ctx == ctx {
+			watcher.enqueue(&core.WatchResponse{Revision: m.revision, Progress: true})
+		}
+	}
+	return nil
+}
+
+// Grant 创建租约
+func (m *Memory) Grant(ctx context.Context, ttl int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.leaseSeq++
+	id := m.leaseSeq
+	m.leases[id] = &memoryLease{
+		ttl:  ttl,
+		keys: make(map[string]struct{}),
+		timer: time.AfterFunc(time.Duration(ttl)*time.Second, func() {
+			_ = m.Revoke(context.Background(), id)
+		}),
+	}
+	return id, nil
+}
+
+// KeepAliveOnce 续约一次
+func (m *Memory) KeepAliveOnce(ctx context.Context, lease int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.leases[lease]
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", core.ErrLeaseNotFound, lease)
+	}
+
+	l.timer.Reset(time.Duration(l.ttl) * time.Second)
+	return l.ttl, nil
+}
+
+// Revoke 撤销租约
+// 说明：
+//   - 绑定的键在同一个版本中删除
+func (m *Memory) Revoke(ctx context.Context, lease int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.leases[lease]
+	if !ok {
+		return fmt.Errorf("%w: %d", core.ErrLeaseNotFound, lease)
+	}
+	l.timer.Stop()
+
+	keys := make([]string, 0, len(l.keys))
+	for key := range l.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ops := make([]core.Op, 0, len(keys))
+	for _, key := range keys {
+		ops = append(ops, core.Op{Type: core.EventTypeDelete, Key: key})
+	}
+	delete(m.leases, lease)
+
+	_, err := m.commit(ops)
+	return err
+}
+
+// commit 在一个新版本中应用操作并通知监听，调用方需持有锁
+// 返回：
+//   - int64: 应用后的版本，没有实际变更时为当前版本
+//   - error: 租约不存在时返回错误，不应用任何操作
+func (m *Memory) commit(ops []core.Op) (int64, error) {
+	for _, op := range ops {
+		if op.Type == core.EventTypePut && op.Lease != 0 {
+			if _, ok := m.leases[op.Lease]; !ok {
+				return 0, fmt.Errorf("%w: %d", core.ErrLeaseNotFound, op.Lease)
+			}
+		}
+	}
+
+	revision := m.revision + 1
+	var events []*core.KvEvent
+	for _, op := range ops {
+		switch op.Type {
+		case core.EventTypePut:
+			events = append(events, m.put(revision, op))
+		case core.EventTypeDelete:
+			for _, key := range m.matchKeys(op.Key, op.Prefix) {
+				events = append(events, m.remove(revision, key))
+			}
+		}
+	}
+
+	if len(events) == 0 {
+		return m.revision, nil
+	}
+
+	m.revision = revision
+	rev := &memoryRevision{revision: revision, events: events}
+	m.history = append(m.history, rev)
+	for watcher := range m.watchers {
+		watcher.send(rev)
+	}
+	return revision, nil
+}
+
+// put 写入键，调用方需持有锁
+func (m *Memory) put(revision int64, op core.Op) *core.KvEvent {
+	prev := m.kvs[op.Key]
+	kv := &core.KeyValue{
+		Key:            op.Key,
+		Value:          append([]byte(nil), op.Value...),
+		CreateRevision: revision,
+		ModRevision:    revision,
+		Version:        1,
+		Lease:          op.Lease,
+	}
+	if prev != nil {
+		kv.CreateRevision = prev.CreateRevision
+		kv.Version = prev.Version + 1
+		m.detachLease(prev)
+	}
+	if kv.Lease != 0 {
+		m.leases[kv.Lease].keys[kv.Key] = struct{}{}
+	}
+
+	m.kvs[op.Key] = kv
+	return &core.KvEvent{Type: core.EventTypePut, Kv: kv, PrevKv: prev}
+}
+
+// remove 删除键，调用方需持有锁
+func (m *Memory) remove(revision int64, key string) *core.KvEvent {
+	prev := m.kvs[key]
+	delete(m.kvs, key)
+	m.detachLease(prev)
+
+	return &core.KvEvent{
+		Type:   core.EventTypeDelete,
+		Kv:     &core.KeyValue{Key: key, ModRevision: revision},
+		PrevKv: prev,
+	}
+}
+
+// detachLease 解除键与租约的绑定，调用方需持有锁
+func (m *Memory) detachLease(kv *core.KeyValue) {
+	if kv.Lease == 0 {
+		return
+	}
+	if l, ok := m.leases[kv.Lease]; ok {
+		delete(l.keys, kv.Key)
+	}
+}
+
+// matchKeys 返回匹配的键，按键排序，调用方需持有锁
+func (m *Memory) matchKeys(key string, prefix bool) []string {
+	if !prefix {
+		if _, ok := m.kvs[key]; ok {
+			return []string{key}
+		}
+		return nil
+	}
+
+	var keys []string
+	for k := range m.kvs {
+		if strings.HasPrefix(k, key) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compare 判断事务条件，调用方需持有锁
+func (m *Memory) compare(cmp core.Compare) (bool, error) {
+	kv := m.kvs[cmp.Key]
+	if kv == nil {
+		kv = &core.KeyValue{}
+	}
+
+	var diff int
+	switch cmp.Target {
+	case core.CompareValue:
+		if kv.Key == "" {
+			// 与 etcd 一致，键不存在时值比较不成立
+			return false, nil
+		}
+		diff = strings.Compare(string(kv.Value), string(cmp.Value))
+	case core.CompareVersion:
+		diff = compareInt(kv.Version, cmp.Revision)
+	case core.CompareCreateRevision:
+		diff = compareInt(kv.CreateRevision, cmp.Revision)
+	case core.CompareModRevision:
+		diff = compareInt(kv.ModRevision, cmp.Revision)
+	default:
+		return false, fmt.Errorf("%w: 未知的比较对象 %d", core.ErrInvalidConfig, cmp.Target)
+	}
+
+	switch cmp.Result {
+	case core.CompareEqual:
+		return diff == 0, nil
+	case core.CompareNotEqual:
+		return diff != 0, nil
+	case core.CompareGreater:
+		return diff > 0, nil
+	case core.CompareLess:
+		return diff < 0, nil
+	default:
+		return false, fmt.Errorf("%w: 未知的比较方式 %q", core.ErrInvalidConfig, cmp.Result)
+	}
+}
+
+// compareInt 比较两个整数
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// cloneKeyValue 复制键值，避免调用方修改内部状态
+func cloneKeyValue(kv *core.KeyValue) *core.KeyValue {
+	if kv == nil {
+		return nil
+	}
+
+	cloned := *kv
+	cloned.Value = append([]byte(nil), kv.Value...)
+	return &cloned
+}
+
+// memoryWatcher 内存监听
+// 说明：
+//   - 写入方只把响应放入无界队列，由独立协程送达，慢消费者不会阻塞写入
+type memoryWatcher struct {
+	ctx     context.Context
+	key     string
+	req     core.WatchRequest
+	out     chan *core.WatchResponse
+	mu      sync.Mutex
+	queue   []*core.WatchResponse
+	signal  chan struct{}
+	closing bool // 队列送达后关闭通道
+}
+
+// newMemoryWatcher 创建内存监听
+func newMemoryWatcher(ctx context.Context, key string, req core.WatchRequest) *memoryWatcher {
+	return &memoryWatcher{
+		ctx:    ctx,
+		key:    key,
+		req:    req,
+		out:    make(chan *core.WatchResponse),
+		signal: make(chan struct{}, 1),
+	}
+}
+
+// send 将版本中匹配的事件放入队列
+func (w *memoryWatcher) send(rev *memoryRevision) {
+	var events []*core.KvEvent
+	for _, event := range rev.events {
+		if !w.matches(event.Kv.Key) {
+			continue
+		}
+
+		delivered := &core.KvEvent{Type: event.Type, Kv: cloneKeyValue(event.Kv)}
+		if w.req.PrevKV {
+			delivered.PrevKv = cloneKeyValue(event.PrevKv)
+		}
+		events = append(events, delivered)
+	}
+
+	if len(events) > 0 {
+		w.enqueue(&core.WatchResponse{Revision: rev.revision, Events: events})
+	}
+}
+
+// matches 判断键是否匹配监听
+func (w *memoryWatcher) matches(key string) bool {
+	if w.req.Prefix {
+		return strings.HasPrefix(key, w.key)
+	}
+	return key == w.key
+}
+
+// enqueue 放入队列并唤醒送达协程
+func (w *memoryWatcher) enqueue(resp *core.WatchResponse) {
+	w.mu.Lock()
+	w.queue = append(w.queue, resp)
+	w.mu.Unlock()
+
+	w.wake()
+}
+
+// finish 标记队列送达后关闭通道
+func (w *memoryWatcher) finish() {
+	w.mu.Lock()
+	w.closing = true
+	w.mu.Unlock()
+
+	w.wake()
+}
+
+// wake 唤醒送达协程
+func (w *memoryWatcher) wake() {
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run 送达队列中的响应，ctx 取消或中断后关闭通道并注销
+func (w *memoryWatcher) run(m *Memory) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.watchers, w)
+		m.mu.Unlock()
+		close(w.out)
+	}()
+
+	for {
+		w.mu.Lock()
+		queue := w.queue
+		w.queue = nil
+		closing := w.closing
+		w.mu.Unlock()
+
+		for _, resp := range queue {
+			select {
+			case w.out <- resp:
+			case <-w.ctx.Done():
+				return
+			}
+		}
+
+		if len(queue) == 0 && closing {
+			return
+		}
+		if len(queue) > 0 {
+			continue
+		}
+
+		select {
+		case <-w.signal:
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// nextResponse 读取下一个监听响应，超时终止测试
+func nextResponse(t *testing.T, ch <-chan *core.WatchResponse) *core.WatchResponse {
+	t.Helper()
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			t.Fatal("监听通道已关闭")
+		}
+		return resp
+	case <-time.After(time.Second):
+		t.Fatal("未收到监听响应")
+		return nil
+	}
+}
+
+// expectClosed 等待监听通道关闭
+func expectClosed(t *testing.T, ch <-chan *core.WatchResponse) {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("监听通道未关闭")
+		}
+	}
+}
+
+func TestMemoryRevisions(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+
+	if rev := mem.Revision(); rev != 1 {
+		t.Fatalf("初始版本 = %d, want 1", rev)
+	}
+
+	steps := []struct {
+		name string
+		op   func() (int64, error)
+		want int64
+	}{
+		{name: "put", op: func() (int64, error) { return mem.Put(ctx, "/a", []byte("1"), 0) }, want: 2},
+		{name: "overwrite", op: func() (int64, error) { return mem.Put(ctx, "/a", []byte("2"), 0) }, want: 3},
+		{name: "put other", op: func() (int64, error) { return mem.Put(ctx, "/b", []byte("1"), 0) }, want: 4},
+		{name: "delete missing", op: func() (int64, error) { return mem.Delete(ctx, "/missing", false) }, want: 4},
+		{name: "delete prefix", op: func() (int64, error) { return mem.Delete(ctx, "/", true) }, want: 5},
+	}
+	for _, step := range steps {
+		got, err := step.op()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got != step.want {
+			t.Fatalf("%s: 版本 = %d, want %d", step.name, got, step.want)
+		}
+	}
+
+	resp, err := mem.Get(ctx, "/", true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(resp.Kvs) != 0 || resp.Revision != 5 {
+		t.Fatalf("Get = %d 个键, 版本 %d, want 0 个键, 版本 5", len(resp.Kvs), resp.Revision)
+	}
+}
+
+func TestMemoryKeyMetadata(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+
+	_, _ = mem.Put(ctx, "/a", []byte("1"), 0)
+	_, _ = mem.Put(ctx, "/a", []byte("2"), 0)
+
+	resp, _ := mem.Get(ctx, "/a", false)
+	kv := resp.Kvs[0]
+	if kv.CreateRevision != 2 || kv.ModRevision != 3 || kv.Version != 2 || string(kv.Value) != "2" {
+		t.Fatalf("键值 = %+v", kv)
+	}
+
+	// 读取结果是副本
+	kv.Value[0] = 'x'
+	resp, _ = mem.Get(ctx, "/a", false)
+	if string(resp.Kvs[0].Value) != "2" {
+		t.Fatal("修改读取结果影响了存储")
+	}
+}
+
+func TestMemoryTxn(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		cmp  core.Compare
+		want bool
+	}{
+		{name: "value equal", cmp: core.Compare{Key: "/a", Target: core.CompareValue, Result: core.CompareEqual, Value: []byte("1")}, want: true},
+		{name: "value not equal", cmp: core.Compare{Key: "/a", Target: core.CompareValue, Result: core.CompareNotEqual, Value: []byte("1")}, want: false},
+		{name: "missing value", cmp: core.Compare{Key: "/missing", Target: core.CompareValue, Result: core.CompareEqual, Value: nil}, want: false},
+		{name: "missing version", cmp: core.Compare{Key: "/missing", Target: core.CompareVersion, Result: core.CompareEqual, Revision: 0}, want: true},
+		{name: "mod revision", cmp: core.Compare{Key: "/a", Target: core.CompareModRevision, Result: core.CompareEqual, Revision: 2}, want: true},
+		{name: "create revision less", cmp: core.Compare{Key: "/a", Target: core.CompareCreateRevision, Result: core.CompareLess, Revision: 2}, want: false},
+		{name: "version greater", cmp: core.Compare{Key: "/a", Target: core.CompareVersion, Result: core.CompareGreater, Revision: 0}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := NewMemory()
+			_, _ = mem.Put(ctx, "/a", []byte("1"), 0)
+
+			resp, err := mem.Txn(ctx, &core.Txn{
+				If:   []core.Compare{tt.cmp},
+				Then: []core.Op{{Type: core.EventTypePut, Key: "/then", Value: []byte("1")}},
+				Else: []core.Op{{Type: core.EventTypePut, Key: "/else", Value: []byte("1")}},
+			})
+			if err != nil {
+				t.Fatalf("Txn: %v", err)
+			}
+			if resp.Succeeded != tt.want {
+				t.Fatalf("Succeeded = %v, want %v", resp.Succeeded, tt.want)
+			}
+
+			key := "/else"
+			if tt.want {
+				key = "/then"
+			}
+			got, _ := mem.Get(ctx, key, false)
+			if len(got.Kvs) != 1 {
+				t.Fatalf("%s 未写入", key)
+			}
+		})
+	}
+}
+
+func TestMemoryTxnSharesRevision(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := NewMemory()
+	watch := mem.Watch(ctx, "/", core.WatchRequest{Prefix: true})
+	nextResponse(t, watch) // Created
+
+	resp, err := mem.Txn(ctx, &core.Txn{Then: []core.Op{
+		{Type: core.EventTypePut, Key: "/a", Value: []byte("1")},
+		{Type: core.EventTypePut, Key: "/b", Value: []byte("1")},
+	}})
+	if err != nil {
+		t.Fatalf("Txn: %v", err)
+	}
+
+	got := nextResponse(t, watch)
+	if len(got.Events) != 2 || got.Revision != resp.Revision {
+		t.Fatalf("响应 = %d 个事件, 版本 %d, want 2 个事件, 版本 %d", len(got.Events), got.Revision, resp.Revision)
+	}
+	for _, ev := range got.Events {
+		if ev.Kv.ModRevision != resp.Revision {
+			t.Fatalf("%s 的 ModRevision = %d, want %d", ev.Kv.Key, ev.Kv.ModRevision, resp.Revision)
+		}
+	}
+}
+
+func TestMemoryWatchReplayAndCompaction(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := NewMemory()
+	for _, value := range []string{"1", "2", "3"} {
+		_, _ = mem.Put(ctx, "/a", []byte(value), 0)
+	}
+
+	// 从未压缩的历史版本回放
+	watch := mem.Watch(ctx, "/a", core.WatchRequest{Revision: 3, PrevKV: true})
+	if resp := nextResponse(t, watch); !resp.Created {
+		t.Fatal("首个响应不是 Created")
+	}
+	for _, want := range []string{"2", "3"} {
+		resp := nextResponse(t, watch)
+		ev := resp.Events[0]
+		if string(ev.Kv.Value) != want || ev.PrevKv == nil {
+			t.Fatalf("回放事件 = %q (prev %v), want %q", ev.Kv.Value, ev.PrevKv, want)
+		}
+	}
+
+	if err := mem.Compact(4); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if err := mem.Compact(4); !errors.Is(err, core.ErrCompacted) {
+		t.Fatalf("重复压缩 = %v, want ErrCompacted", err)
+	}
+	if err := mem.Compact(100); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Fatalf("压缩未来版本 = %v, want ErrInvalidConfig", err)
+	}
+
+	// 起始版本已被压缩
+	compacted := mem.Watch(ctx, "/a", core.WatchRequest{Revision: 2})
+	nextResponse(t, compacted) // Created
+	if resp := nextResponse(t, compacted); resp.CompactRevision != 4 {
+		t.Fatalf("CompactRevision = %d, want 4", resp.CompactRevision)
+	}
+	expectClosed(t, compacted)
+
+	// 压缩版本本身仍可回放
+	replay := mem.Watch(ctx, "/a", core.WatchRequest{Revision: 4})
+	nextResponse(t, replay) // Created
+	if resp := nextResponse(t, replay); resp.Revision != 4 {
+		t.Fatalf("回放版本 = %d, want 4", resp.Revision)
+	}
+}
+
+func TestMemoryDropWatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := NewMemory()
+	watch := mem.Watch(ctx, "/", core.WatchRequest{Prefix: true})
+	nextResponse(t, watch) // Created
+
+	_, _ = mem.Put(ctx, "/a", []byte("1"), 0)
+	mem.DropWatches()
+
+	// 已排队的响应先送达再关闭
+	if resp := nextResponse(t, watch); len(resp.Events) != 1 {
+		t.Fatalf("事件数 = %d, want 1", len(resp.Events))
+	}
+	expectClosed(t, watch)
+}
+
+func TestMemoryRequestProgress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := NewMemory()
+	watch := mem.Watch(ctx, "/", core.WatchRequest{Prefix: true})
+	nextResponse(t, watch) // Created
+
+	_, _ = mem.Put(ctx, "/a", []byte("1"), 0)
+	if err := mem.RequestProgress(ctx); err != nil {
+		t.Fatalf("RequestProgress: %v", err)
+	}
+
+	if resp := nextResponse(t, watch); len(resp.Events) != 1 {
+		t.Fatal("进度通知先于已产生的变更送达")
+	}
+	if resp := nextResponse(t, watch); !resp.Progress || resp.Revision != 2 {
+		t.Fatalf("响应 = %+v, want 版本 2 的进度通知", resp)
+	}
+}
+
+func TestMemoryLeases(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := NewMemory()
+	if _, err := mem.Put(ctx, "/a", []byte("1"), 42); !errors.Is(err, core.ErrLeaseNotFound) {
+		t.Fatalf("使用不存在的租约写入 = %v, want ErrLeaseNotFound", err)
+	}
+
+	lease, err := mem.Grant(ctx, 60)
+	if err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+	_, _ = mem.Put(ctx, "/a", []byte("1"), lease)
+	_, _ = mem.Put(ctx, "/b", []byte("1"), lease)
+	_, _ = mem.Put(ctx, "/c", []byte("1"), lease)
+	_, _ = mem.Put(ctx, "/c", []byte("2"), 0) // 覆盖后解除绑定
+
+	if ttl, err := mem.KeepAliveOnce(ctx, lease); err != nil || ttl != 60 {
+		t.Fatalf("KeepAliveOnce = %d, %v", ttl, err)
+	}
+
+	watch := mem.Watch(ctx, "/", core.WatchRequest{Prefix: true})
+	nextResponse(t, watch) // Created
+
+	if err := mem.Revoke(ctx, lease); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	// 绑定的键在同一版本中删除
+	resp := nextResponse(t, watch)
+	if len(resp.Events) != 2 {
+		t.Fatalf("撤销租约产生 %d 个事件, want 2", len(resp.Events))
+	}
+	for _, ev := range resp.Events {
+		if ev.Type != core.EventTypeDelete {
+			t.Fatalf("%s 的事件类型 = %s, want DELETE", ev.Kv.Key, ev.Type)
+		}
+	}
+
+	got, _ := mem.Get(ctx, "/", true)
+	if len(got.Kvs) != 1 || got.Kvs[0].Key != "/c" {
+		t.Fatalf("剩余键 = %v, want [/c]", got.Kvs)
+	}
+
+	if _, err := mem.KeepAliveOnce(ctx, lease); !errors.Is(err, core.ErrLeaseNotFound) {
+		t.Fatalf("续约已撤销的租约 = %v, want ErrLeaseNotFound", err)
+	}
+}
+
+func TestMemoryLeaseExpiry(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+
+	lease, _ := mem.Grant(ctx, 1)
+	_, _ = mem.Put(ctx, "/a", []byte("1"), lease)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		resp, _ := mem.Get(ctx, "/a", false)
+		if len(resp.Kvs) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("租约到期后键未删除")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
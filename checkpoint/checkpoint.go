@@ -0,0 +1,31 @@
+// Package checkpoint 提供 core.CheckpointStore 的内置实现。
+//
+// 检查点记录订阅最后成功处理的 etcd 版本，配合 core.WithCheckpoint 使用：
+//   - FileStore: 保存在本地目录，每个检查点一个文件，原子替换写入
+//   - EtcdStore / BackendStore: 保存在 etcd 或任意存储后端的指定前缀下，适合无本地持久卷的 Pod
+//
+// 使用示例：
+//
+//	store, _ := checkpoint.NewFileStore("/var/lib/my-service/checkpoints")
+//	sub, err := eng.Watch("/app/events/", handler, core.WithCheckpoint(store))
+package checkpoint
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseRevision 解析检查点内容，内容为空时返回 0
+func parseRevision(data []byte) (int64, error) {
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(text, 10, 64)
+}
+
+// formatRevision 格式化检查点内容
+func formatRevision(revision int64) string {
+	return strconv.FormatInt(revision, 10)
+}
@@ -0,0 +1,96 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// testStore 检查点存储的通用行为
+func testStore(t *testing.T, store core.CheckpointStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	if revision, err := store.Load(ctx, "/jobs/"); revision != 0 || err != nil {
+		t.Fatalf("未保存时 Load = %d, %v, want 0, nil", revision, err)
+	}
+
+	for _, revision := range []int64{12, 7} {
+		if err := store.Save(ctx, "/jobs/", revision); err != nil {
+			t.Fatalf("Save(%d): %v", revision, err)
+		}
+		if got, err := store.Load(ctx, "/jobs/"); got != revision || err != nil {
+			t.Fatalf("Load = %d, %v, want %d", got, err, revision)
+		}
+	}
+
+	// 不同名称互不影响
+	if err := store.Save(ctx, "/jobs/a/b", 3); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if got, _ := store.Load(ctx, "/jobs/"); got != 7 {
+		t.Fatalf("Load(/jobs/) = %d, want 7", got)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkpoints")
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	testStore(t, store)
+
+	// 只保留转义后的检查点文件，不残留临时文件
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 2 || names[0] != "%2Fjobs%2F.rev" || names[1] != "%2Fjobs%2Fa%2Fb.rev" {
+		t.Fatalf("检查点文件 = %v", names)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "%2Fbad.rev"), []byte("abc"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := store.Load(context.Background(), "/bad"); !errors.Is(err, core.ErrCheckpointLoadFailed) {
+		t.Fatalf("损坏的检查点 Load = %v, want ErrCheckpointLoadFailed", err)
+	}
+}
+
+func TestFileStoreInvalidDir(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := NewFileStore(filepath.Join(file, "checkpoints")); !errors.Is(err, core.ErrCheckpointSaveFailed) {
+		t.Fatalf("NewFileStore = %v, want ErrCheckpointSaveFailed", err)
+	}
+}
+
+func TestBackendStore(t *testing.T) {
+	mem := backend.NewMemory()
+	testStore(t, NewBackendStore(mem, "/checkpoints"))
+
+	ctx := context.Background()
+	resp, err := mem.Get(ctx, "/checkpoints/jobs/", false)
+	if err != nil || len(resp.Kvs) != 1 || string(resp.Kvs[0].Value) != "7" {
+		t.Fatalf("检查点键 = %v, %v, want 7", resp, err)
+	}
+
+	if _, err := mem.Put(ctx, "/checkpoints/bad", []byte("abc"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := NewBackendStore(mem, "/checkpoints").Load(ctx, "/bad"); !errors.Is(err, core.ErrCheckpointLoadFailed) {
+		t.Fatalf("损坏的检查点 Load = %v, want ErrCheckpointLoadFailed", err)
+	}
+}
@@ -0,0 +1,76 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// backendStore 存储后端检查点存储
+type backendStore struct {
+	backend core.Backend
+	prefix  string
+}
+
+// NewEtcdStore 创建 etcd 检查点存储
+// 参数：
+//   - client: etcd 客户端（由调用方管理生命周期）
+//   - prefix: 检查点键前缀，建议包含服务或 Pod 名称，如 "/checkpoints/my-service"
+//
+// 返回：
+//   - core.CheckpointStore: 检查点存储
+//
+// 说明：
+//   - 检查点保存在 prefix + 检查点名称 下
+//   - 前缀不要与被监听的前缀重叠，否则保存检查点会触发新的事件
+func NewEtcdStore(client *clientv3.Client, prefix string) core.CheckpointStore {
+	return NewBackendStore(backend.NewEtcd(client), prefix)
+}
+
+// NewBackendStore 创建保存在存储后端的检查点存储
+// 参数：
+//   - b: 存储后端，如 Engine.Backend() 或 backend.NewMemory()
+//   - prefix: 检查点键前缀
+//
+// 返回：
+//   - core.CheckpointStore: 检查点存储
+//
+// 说明：
+//   - 与 NewEtcdStore 相同，前缀不要与被监听的前缀重叠
+func NewBackendStore(b core.Backend, prefix string) core.CheckpointStore {
+	return &backendStore{
+		backend: b,
+		prefix:  prefix,
+	}
+}
+
+// Load 读取检查点
+func (s *backendStore) Load(ctx context.Context, name string) (int64, error) {
+	resp, err := s.backend.Get(ctx, s.prefix+name, false)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", core.ErrCheckpointLoadFailed, err)
+	}
+
+	if len(resp.Kvs) == 0 {
+		return 0, nil
+	}
+
+	revision, err := parseRevision(resp.Kvs[0].Value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", core.ErrCheckpointLoadFailed, err)
+	}
+
+	return revision, nil
+}
+
+// Save 保存检查点
+func (s *backendStore) Save(ctx context.Context, name string, revision int64) error {
+	if _, err := s.backend.Put(ctx, s.prefix+name, []byte(formatRevision(revision)), 0); err != nil {
+		return fmt.Errorf("%w: %v", core.ErrCheckpointSaveFailed, err)
+	}
+
+	return nil
+}
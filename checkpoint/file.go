@@ -0,0 +1,88 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// fileStore 本地文件检查点存储
+type fileStore struct {
+	dir string
+}
+
+// NewFileStore 创建本地文件检查点存储
+// 参数：
+//   - dir: 检查点目录，不存在时自动创建
+//
+// 返回：
+//   - core.CheckpointStore: 检查点存储
+//   - error: 目录创建失败时返回错误
+//
+// 说明：
+//   - 检查点名称转义后作为文件名
+//   - 写入临时文件后原子重命名，进程崩溃不会留下不完整的检查点
+func NewFileStore(dir string) (core.CheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("%w: %v", core.ErrCheckpointSaveFailed, err)
+	}
+
+	return &fileStore{dir: dir}, nil
+}
+
+// Load 读取检查点
+func (s *fileStore) Load(_ context.Context, name string) (int64, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", core.ErrCheckpointLoadFailed, err)
+	}
+
+	revision, err := parseRevision(data)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", core.ErrCheckpointLoadFailed, err)
+	}
+
+	return revision, nil
+}
+
+// Save 保存检查点
+func (s *fileStore) Save(_ context.Context, name string, revision int64) error {
+	tmp, err := os.CreateTemp(s.dir, ".checkpoint-*")
+	if err != nil {
+		return fmt.Errorf("%w: %v", core.ErrCheckpointSaveFailed, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(formatRevision(revision)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w: %v", core.ErrCheckpointSaveFailed, err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w: %v", core.ErrCheckpointSaveFailed, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%w: %v", core.ErrCheckpointSaveFailed, err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(name)); err != nil {
+		return fmt.Errorf("%w: %v", core.ErrCheckpointSaveFailed, err)
+	}
+
+	return nil
+}
+
+// path 返回检查点文件路径
+func (s *fileStore) path(name string) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+".rev")
+}
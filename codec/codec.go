@@ -0,0 +1,34 @@
+// Package codec 提供 core.Codec 的内置实现。
+//
+// 通过 core.WatchConfig.Codec 为每个预加载路径选择编解码器，未指定时使用 JSON：
+//
+//	Configs: []core.WatchConfig{
+//	    {Path: "/app/config/database/", Struct: &DatabaseConfig{}},
+//	    {Path: "/app/config/gateway/", Struct: &GatewayConfig{}, Codec: codec.YAML},
+//	    {Path: "/app/config/rules/", Struct: &pb.Rules{}, Codec: codec.Protobuf},
+//	}
+//
+// PutConfig 写入时使用与目标键最长匹配的预加载路径的编解码器。
+//
+// 需要与 go-zero conf.MustLoad 一致的 default=、options=、range=、optional 标签语义时，
+// 使用 MappingJSON、MappingYAML 或 MappingTOML。
+package codec
+
+import "github.com/rezeropoint/etcdtrigger/v2/core"
+
+// 内置编解码器
+var (
+	JSON     core.Codec = jsonCodec{}     // JSON，兼容标准库 encoding/json 的标签与行为
+	YAML     core.Codec = yamlCodec{}     // YAML，使用 yaml 标签
+	TOML     core.Codec = tomlCodec{}     // TOML，使用 toml 标签
+	Protobuf core.Codec = protobufCodec{} // Protobuf 二进制，Struct 必须实现 proto.Message
+	Msgpack  core.Codec = msgpackCodec{}  // MessagePack，使用 msgpack 标签
+)
+
+// OrDefault 返回 c，c 为 nil 时返回 JSON
+func OrDefault(c core.Codec) core.Codec {
+	if c == nil {
+		return JSON
+	}
+	return c
+}
@@ -0,0 +1,154 @@
+package codec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type roundTrip struct {
+	Name  string            `json:"name" yaml:"name" toml:"name" msgpack:"name"`
+	Port  int               `json:"port" yaml:"port" toml:"port" msgpack:"port"`
+	Tags  []string          `json:"tags" yaml:"tags" toml:"tags" msgpack:"tags"`
+	Extra map[string]string `json:"extra" yaml:"extra" toml:"extra" msgpack:"extra"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	want := roundTrip{Name: "db", Port: 3306, Tags: []string{"a", "b"}, Extra: map[string]string{"k": "v"}}
+
+	for _, c := range []core.Codec{JSON, YAML, TOML, Msgpack, MappingJSON, MappingYAML, MappingTOML} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(want)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var got roundTrip
+			if err := c.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal(%s): %v", data, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Unmarshal = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestProtobufCodec(t *testing.T) {
+	data, err := Protobuf.Marshal(wrapperspb.String("db"))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	got := &wrapperspb.StringValue{}
+	if err := Protobuf.Unmarshal(data, got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !proto.Equal(got, wrapperspb.String("db")) {
+		t.Fatalf("Unmarshal = %v, want db", got)
+	}
+
+	if _, err := Protobuf.Marshal(roundTrip{}); err == nil {
+		t.Fatal("非 proto.Message 的 Marshal 应返回错误")
+	}
+	if err := Protobuf.Unmarshal(data, &roundTrip{}); err == nil {
+		t.Fatal("非 proto.Message 的 Unmarshal 应返回错误")
+	}
+}
+
+func TestOrDefault(t *testing.T) {
+	if OrDefault(nil) != JSON {
+		t.Fatal("OrDefault(nil) 应返回 JSON")
+	}
+	if OrDefault(YAML) != YAML {
+		t.Fatal("OrDefault(YAML) 应返回 YAML")
+	}
+}
+
+type database struct {
+	Host string   `json:"host"`
+	Port int      `json:"port,default=3306"`
+	Mode string   `json:"mode,options=ro|rw,default=rw"`
+	Tags []string `json:"tags,optional"`
+}
+
+// checkedDatabase 统计 Validate 的调用次数
+type checkedDatabase struct {
+	Host string `json:"host"`
+
+	validated int
+}
+
+func (c *checkedDatabase) Validate() error {
+	c.validated++
+	if c.Host == "reserved" {
+		return errors.New("保留的主机名")
+	}
+	return nil
+}
+
+func TestMappingCodec(t *testing.T) {
+	tests := []struct {
+		name    string
+		codec   core.Codec
+		data    string
+		want    database
+		wantErr bool
+	}{
+		{name: "defaults", codec: MappingJSON, data: `{"host":"db"}`, want: database{Host: "db", Port: 3306, Mode: "rw"}},
+		{name: "case insensitive", codec: MappingJSON, data: `{"HOST":"db","Port":5432}`, want: database{Host: "db", Port: 5432, Mode: "rw"}},
+		{name: "yaml", codec: MappingYAML, data: "host: db\nmode: ro\n", want: database{Host: "db", Port: 3306, Mode: "ro"}},
+		{name: "toml", codec: MappingTOML, data: "host = \"db\"\ntags = [\"a\"]\n", want: database{Host: "db", Port: 3306, Mode: "rw", Tags: []string{"a"}}},
+		{name: "missing required", codec: MappingJSON, data: `{"port":1}`, wantErr: true},
+		{name: "option mismatch", codec: MappingJSON, data: `{"host":"db","mode":"wo"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got database
+			err := tt.codec.Unmarshal([]byte(tt.data), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal = %+v, want 错误", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Unmarshal = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMappingCodecSkipsValidate(t *testing.T) {
+	for _, c := range []core.Codec{MappingJSON, MappingYAML, MappingTOML} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(map[string]any{"host": "reserved"})
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			got := &checkedDatabase{}
+			if err := c.Unmarshal(data, got); err != nil {
+				t.Fatalf("Validate 失败不应导致解码失败: %v", err)
+			}
+			if got.Host != "reserved" || got.validated != 0 {
+				t.Fatalf("Unmarshal = %+v, want Host reserved 且未调用 Validate", got)
+			}
+		})
+	}
+}
+
+func TestMappingCodecTree(t *testing.T) {
+	var tree map[string]any
+	if err := MappingYAML.Unmarshal([]byte("Host: db\n"), &tree); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if tree["Host"] != "db" {
+		t.Fatalf("通用结构 = %v, want 保留原始键 Host", tree)
+	}
+}
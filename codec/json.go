@@ -0,0 +1,23 @@
+package codec
+
+import jsoniter "github.com/json-iterator/go"
+
+var jsonIter = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// jsonCodec JSON 编解码器
+type jsonCodec struct{}
+
+// Name 编解码器名称
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+// Marshal 编码
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return jsonIter.Marshal(v)
+}
+
+// Unmarshal 解码
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return jsonIter.Unmarshal(data, v)
+}
@@ -0,0 +1,109 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/zeromicro/go-zero/core/conf"
+	"gopkg.in/yaml.v3"
+)
+
+// go-zero 映射编解码器
+// 解码与 conf.MustLoad 语义一致：
+//   - 字段按 json 标签匹配且不区分大小写
+//   - 支持 default=、options=、range=、optional 等标签选项，缺少必填字段时解码失败
+//   - 不调用结构体的 Validate() error，校验由存储层在解码后执行，失败时返回 core.ErrValidationFailed
+//
+// 编码按 json 标签生成对应格式，保证写入的值能以相同规则解码
+var (
+	MappingJSON core.Codec = mappingCodec{name: "mapping-json", load: conf.LoadFromJsonBytes, decode: jsonIter.Unmarshal, encode: jsonIter.Marshal}
+	MappingYAML core.Codec = mappingCodec{name: "mapping-yaml", load: conf.LoadFromYamlBytes, decode: yaml.Unmarshal, encode: yaml.Marshal}
+	MappingTOML core.Codec = mappingCodec{name: "mapping-toml", load: conf.LoadFromTomlBytes, decode: toml.Unmarshal, encode: toml.Marshal}
+)
+
+// mappingCodec 基于 go-zero 映射规则的编解码器
+type mappingCodec struct {
+	name   string
+	load   func(content []byte, v any) error
+	decode func(data []byte, v any) error // 将目标格式解码为通用结构，用于分层合并
+	encode func(v any) ([]byte, error)    // 将 JSON 结构编码为目标格式
+}
+
+// Name 编解码器名称
+func (c mappingCodec) Name() string {
+	return c.name
+}
+
+// Marshal 编码
+// 说明：
+//   - 先按 json 标签编码为 JSON，再转换为目标格式
+//   - null 值被省略，与缺少该字段等价，避免映射规则将 null 解码失败
+func (c mappingCodec) Marshal(v any) ([]byte, error) {
+	data, err := jsonIter.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree any
+	decoder := jsonIter.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	return c.encode(plain(tree))
+}
+
+// Unmarshal 解码
+// 说明：
+//   - v 为 *map[string]any 时按原格式解码，不应用映射规则
+//   - conf 解码后会调用 Validate，v 实现 core.Validator 时经二级指针解码后复制回 v，
+//     避免重复校验，也避免校验失败被当作反序列化失败
+func (c mappingCodec) Unmarshal(data []byte, v any) error {
+	if tree, ok := v.(*map[string]any); ok {
+		return c.decode(data, tree)
+	}
+	if _, ok := v.(core.Validator); !ok {
+		return c.load(data, v)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return c.load(data, v)
+	}
+	target := reflect.New(rv.Type())
+	if err := c.load(data, target.Interface()); err != nil {
+		return err
+	}
+	rv.Elem().Set(target.Elem().Elem())
+	return nil
+}
+
+// plain 将 json.Number 转换为整数或浮点数，避免目标格式将数字编码为字符串，并省略对象中的 null 值
+func plain(tree any) any {
+	switch value := tree.(type) {
+	case map[string]any:
+		for k, child := range value {
+			if child == nil {
+				delete(value, k)
+				continue
+			}
+			value[k] = plain(child)
+		}
+	case []any:
+		for i, child := range value {
+			value[i] = plain(child)
+		}
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+	}
+	return tree
+}
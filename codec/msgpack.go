@@ -0,0 +1,21 @@
+package codec
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// msgpackCodec MessagePack 编解码器
+type msgpackCodec struct{}
+
+// Name 编解码器名称
+func (msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+// Marshal 编码
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// Unmarshal 解码
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
@@ -0,0 +1,33 @@
+package codec
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// protobufCodec Protobuf 编解码器
+type protobufCodec struct{}
+
+// Name 编解码器名称
+func (protobufCodec) Name() string {
+	return "protobuf"
+}
+
+// Marshal 编码，v 必须实现 proto.Message
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T 未实现 proto.Message", v)
+	}
+	return proto.Marshal(message)
+}
+
+// Unmarshal 解码，v 必须实现 proto.Message
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T 未实现 proto.Message", v)
+	}
+	return proto.Unmarshal(data, message)
+}
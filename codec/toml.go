@@ -0,0 +1,21 @@
+package codec
+
+import "github.com/pelletier/go-toml/v2"
+
+// tomlCodec TOML 编解码器
+type tomlCodec struct{}
+
+// Name 编解码器名称
+func (tomlCodec) Name() string {
+	return "toml"
+}
+
+// Marshal 编码
+func (tomlCodec) Marshal(v any) ([]byte, error) {
+	return toml.Marshal(v)
+}
+
+// Unmarshal 解码
+func (tomlCodec) Unmarshal(data []byte, v any) error {
+	return toml.Unmarshal(data, v)
+}
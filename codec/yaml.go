@@ -0,0 +1,21 @@
+package codec
+
+import "gopkg.in/yaml.v3"
+
+// yamlCodec YAML 编解码器
+type yamlCodec struct{}
+
+// Name 编解码器名称
+func (yamlCodec) Name() string {
+	return "yaml"
+}
+
+// Marshal 编码
+func (yamlCodec) Marshal(v any) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// Unmarshal 解码
+func (yamlCodec) Unmarshal(data []byte, v any) error {
+	return yaml.Unmarshal(data, v)
+}
@@ -0,0 +1,158 @@
+package core
+
+import "context"
+
+// Backend 键值存储后端
+// 引擎通过该接口访问存储，默认实现基于 etcd，测试可使用内存实现
+// 说明：
+//   - 语义与 etcd v3 一致：全局递增的版本、前缀监听、事务与租约
+//   - 实现必须是并发安全的
+type Backend interface {
+	// Get 读取键或前缀
+	// 参数：
+	//   - key: 键或前缀
+	//   - prefix: 为 true 时读取前缀下的所有键
+	// 返回：
+	//   - *GetResult: 读取结果，键不存在时 Kvs 为空
+	//   - error: 读取失败时返回错误
+	Get(ctx context.Context, key string, prefix bool) (*GetResult, error)
+
+	// Put 写入键
+	// 参数：
+	//   - lease: 绑定的租约 ID，0 表示不绑定
+	// 返回：
+	//   - int64: 写入后的版本
+	//   - error: 写入失败或租约不存在时返回错误
+	Put(ctx context.Context, key string, value []byte, lease int64) (int64, error)
+
+	// Delete 删除键或前缀
+	// 返回：
+	//   - int64: 删除后的版本，没有键被删除时为当前版本
+	//   - error: 删除失败时返回错误
+	Delete(ctx context.Context, key string, prefix bool) (int64, error)
+
+	// Txn 执行事务
+	// 说明：
+	//   - If 中的条件全部成立时执行 Then，否则执行 Else
+	//   - 同一事务的所有写入共享一个版本
+	Txn(ctx context.Context, txn *Txn) (*TxnResult, error)
+
+	// Watch 监听键或前缀
+	// 返回：
+	//   - <-chan *WatchResponse: 响应通道，ctx 取消或监听中断时关闭
+	// 说明：
+	//   - 建立后首先发送 Created 响应
+	//   - 起始版本已被压缩时发送 CompactRevision 非 0 的响应后关闭
+	Watch(ctx context.Context, key string, req WatchRequest) <-chan *WatchResponse
+
+	// RequestProgress 请求监听进度
+	// 说明：
+	//   - ctx 必须与 Watch 使用的 ctx 相同
+	//   - 监听追上当前版本后发送 Progress 响应
+	RequestProgress(ctx context.Context) error
+
+	// Grant 创建租约
+	// 返回：
+	//   - int64: 租约 ID
+	Grant(ctx context.Context, ttl int64) (int64, error)
+
+	// KeepAliveOnce 续约一次
+	// 返回：
+	//   - int64: 续约后的剩余秒数
+	//   - error: 租约不存在或已过期时返回错误
+	KeepAliveOnce(ctx context.Context, lease int64) (int64, error)
+
+	// Revoke 撤销租约，绑定的键全部删除
+	Revoke(ctx context.Context, lease int64) error
+}
+
+// KeyValue 键值及其版本元数据
+type KeyValue struct {
+	Key            string
+	Value          []byte
+	CreateRevision int64 // 键创建时的版本
+	ModRevision    int64 // 键最后修改的版本
+	Version        int64 // 键自创建以来的修改次数
+	Lease          int64 // 绑定的租约 ID，0 表示无租约
+}
+
+// GetResult 读取结果
+type GetResult struct {
+	Revision int64       // 读取时的存储版本
+	Kvs      []*KeyValue // 按键排序
+}
+
+// KvEvent 存储变更事件
+type KvEvent struct {
+	Type   EventType // PUT 或 DELETE
+	Kv     *KeyValue // 变更后的键值，DELETE 时只有 Key 与 ModRevision（删除发生的版本）
+	PrevKv *KeyValue // 变更前的键值，仅在 WatchRequest.PrevKV 为 true 时提供
+}
+
+// WatchRequest 监听参数
+type WatchRequest struct {
+	Prefix   bool  // 是否监听前缀
+	Revision int64 // 起始版本（包含），0 表示从下一个版本开始
+	PrevKV   bool  // 事件是否携带变更前的键值
+}
+
+// WatchResponse 监听响应
+type WatchResponse struct {
+	Revision        int64      // 响应时的存储版本
+	Events          []*KvEvent // 变更事件
+	Created         bool       // 监听建立通知
+	Progress        bool       // 进度通知，此前的变更已全部送达
+	CompactRevision int64      // 非 0 表示起始版本已被压缩
+	Err             error      // 监听错误
+}
+
+// CompareTarget 事务条件比较的对象
+type CompareTarget int
+
+const (
+	CompareValue          CompareTarget = iota // 值
+	CompareVersion                             // 修改次数，键不存在时为 0
+	CompareCreateRevision                      // 创建版本，键不存在时为 0
+	CompareModRevision                         // 最后修改版本，键不存在时为 0
+)
+
+// CompareResult 事务条件的比较方式
+type CompareResult string
+
+const (
+	CompareEqual    CompareResult = "="
+	CompareNotEqual CompareResult = "!="
+	CompareGreater  CompareResult = ">"
+	CompareLess     CompareResult = "<"
+)
+
+// Compare 事务条件
+type Compare struct {
+	Key      string
+	Target   CompareTarget
+	Result   CompareResult
+	Value    []byte // Target 为 CompareValue 时比较的值
+	Revision int64  // Target 为版本类时比较的数值
+}
+
+// Op 事务操作
+type Op struct {
+	Type   EventType // PUT 或 DELETE
+	Key    string
+	Value  []byte // PUT 的值
+	Lease  int64  // PUT 绑定的租约 ID
+	Prefix bool   // DELETE 是否删除前缀
+}
+
+// Txn 事务
+type Txn struct {
+	If   []Compare
+	Then []Op
+	Else []Op
+}
+
+// TxnResult 事务结果
+type TxnResult struct {
+	Succeeded bool  // If 条件是否全部成立
+	Revision  int64 // 事务执行后的版本
+}
@@ -0,0 +1,23 @@
+package core
+
+import "context"
+
+// CheckpointStore 检查点存储
+// 用于持久化订阅最后成功处理的 etcd 版本，进程重启后从该版本续接
+type CheckpointStore interface {
+	// Load 读取检查点
+	// 参数：
+	//   - name: 检查点名称，默认为订阅的键或前缀，可由 WithCheckpointName 指定
+	// 返回：
+	//   - int64: 最后成功处理的版本，不存在时返回 0
+	//   - error: 读取失败时返回错误
+	Load(ctx context.Context, name string) (int64, error)
+
+	// Save 保存检查点
+	// 参数：
+	//   - name: 检查点名称
+	//   - revision: 最后成功处理的版本
+	// 返回：
+	//   - error: 写入失败时返回错误
+	Save(ctx context.Context, name string, revision int64) error
+}
@@ -0,0 +1,9 @@
+package core
+
+// Codec 配置编解码器
+// 用于 Store 在 etcd 中的原始字节与结构体之间转换，可按 WatchConfig 单独指定
+type Codec interface {
+	Name() string                       // 编解码器名称（日志用）
+	Marshal(v any) ([]byte, error)      // 编码
+	Unmarshal(data []byte, v any) error // 解码，v 为指向结构体的指针
+}
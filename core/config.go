@@ -2,7 +2,15 @@ package core
 
 // WatchConfig 监听配置项
 // 用于定义需要监听的键及其绑定的结构体类型
+// 说明：
+//   - 设置 Layers 时不再监听 Path 本身，Path 作为逻辑键前缀
+//   - 逻辑键 Path+后缀 的值由各层中 层前缀+后缀 的值深度合并而成，任一层变化时重新合并
+//   - {service} 与 {pod} 分别替换为引擎配置的 ServiceName 与 PodName
+//   - 分层合并按 map[string]any 解码各层，Codec 必须支持通用对象，Protobuf 不能与 Layers 同时使用
 type WatchConfig struct {
-	Path   string // 监听路径（支持前缀）
-	Struct any    // 绑定的结构体实例（用于 JSON 反序列化，可为 nil）
+	Path     string       // 监听路径（支持前缀）
+	Struct   any          // 绑定的结构体实例（用于反序列化，可为 nil）
+	Codec    Codec        // 编解码器（可为 nil，默认 JSON）
+	Validate ValidateFunc // 校验函数（可为 nil），在结构体自身的 Validate 方法之后执行
+	Layers   []string     // 分层路径模板（可为空），优先级从低到高，支持 {service} 与 {pod} 占位符
 }
@@ -0,0 +1,39 @@
+package core
+
+import "time"
+
+// DeadLetter 死信
+// 说明：
+//   - Watch 回调重试耗尽后，事件连同元数据与最后一次的错误以 JSON 写入死信前缀下
+//   - 死信键为死信前缀 + ID，ID 由事件版本（20 位补零）与事件键组成，按版本排序
+type DeadLetter struct {
+	ID             string    `json:"-"`                   // 死信前缀下的键名，由 Engine.ListDeadLetters 填充
+	Subscription   string    `json:"subscription"`        // 订阅的键或前缀
+	Key            string    `json:"key"`                 // 事件键
+	Value          []byte    `json:"value,omitempty"`     // 事件值
+	PrevValue      []byte    `json:"prevValue,omitempty"` // 变更前的值
+	EventType      EventType `json:"eventType"`           // 事件类型
+	Revision       int64     `json:"revision"`            // 事件版本
+	CreateRevision int64     `json:"createRevision"`      // 键创建时的版本
+	ModRevision    int64     `json:"modRevision"`         // 键最后修改的版本
+	Version        int64     `json:"version"`             // 键自创建以来的修改次数
+	Lease          int64     `json:"lease"`               // 键绑定的租约 ID
+	Attempts       int       `json:"attempts"`            // 累计尝试次数（含重放）
+	Error          string    `json:"error"`               // 最后一次失败的错误
+	FailedAt       time.Time `json:"failedAt"`            // 最后一次失败的时间
+}
+
+// Event 还原死信对应的监听事件
+func (d *DeadLetter) Event() *WatchEvent {
+	return &WatchEvent{
+		Key:            d.Key,
+		Value:          d.Value,
+		EventType:      d.EventType,
+		Revision:       d.Revision,
+		CreateRevision: d.CreateRevision,
+		ModRevision:    d.ModRevision,
+		Version:        d.Version,
+		Lease:          d.Lease,
+		PrevValue:      d.PrevValue,
+	}
+}
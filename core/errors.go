@@ -4,31 +4,68 @@ import "errors"
 
 // 预定义错误 - 连接相关
 var (
-	ErrConnectionFailed    = errors.New("etcd connection failed")
-	ErrConnectionClosed    = errors.New("etcd connection closed")
-	ErrConnectionTimeout   = errors.New("etcd connection timeout")
+	ErrConnectionFailed     = errors.New("etcd connection failed")
+	ErrConnectionClosed     = errors.New("etcd connection closed")
+	ErrConnectionTimeout    = errors.New("etcd connection timeout")
 	ErrAuthenticationFailed = errors.New("etcd authentication failed")
 )
 
 // 预定义错误 - 配置相关
 var (
-	ErrInvalidConfig      = errors.New("invalid config")
-	ErrConfigNotFound     = errors.New("config not found in cache")
-	ErrConfigEmpty        = errors.New("config is empty")
-	ErrEndpointsEmpty     = errors.New("etcd endpoints cannot be empty")
+	ErrInvalidConfig       = errors.New("invalid config")
+	ErrConfigNotFound      = errors.New("config not found in cache")
+	ErrConfigAlreadyExists = errors.New("config path already registered")
+	ErrConfigEmpty         = errors.New("config is empty")
+	ErrEndpointsEmpty      = errors.New("etcd endpoints cannot be empty")
 )
 
 // 预定义错误 - 操作相关
 var (
-	ErrPutFailed      = errors.New("etcd put operation failed")
-	ErrDeleteFailed   = errors.New("etcd delete operation failed")
-	ErrGetFailed      = errors.New("etcd get operation failed")
-	ErrWatchFailed    = errors.New("etcd watch failed")
-	ErrWatchCanceled  = errors.New("etcd watch canceled")
+	ErrPutFailed     = errors.New("etcd put operation failed")
+	ErrDeleteFailed  = errors.New("etcd delete operation failed")
+	ErrGetFailed     = errors.New("etcd get operation failed")
+	ErrWatchFailed   = errors.New("etcd watch failed")
+	ErrWatchCanceled = errors.New("etcd watch canceled")
+)
+
+// 预定义错误 - 检查点相关
+var (
+	ErrCheckpointLoadFailed = errors.New("checkpoint load failed")
+	ErrCheckpointSaveFailed = errors.New("checkpoint save failed")
 )
 
 // 预定义错误 - 序列化相关
 var (
-	ErrMarshalFailed   = errors.New("json marshal failed")
-	ErrUnmarshalFailed = errors.New("json unmarshal failed")
+	ErrMarshalFailed   = errors.New("config marshal failed")
+	ErrUnmarshalFailed = errors.New("config unmarshal failed")
+)
+
+// 预定义错误 - 校验相关
+var (
+	ErrValidationFailed = errors.New("config validation failed")
+)
+
+// 预定义错误 - 快照相关
+var (
+	ErrSnapshotLoadFailed = errors.New("snapshot load failed")
+	ErrSnapshotSaveFailed = errors.New("snapshot save failed")
+	ErrSnapshotCorrupted  = errors.New("snapshot checksum mismatch")
+)
+
+// 预定义错误 - 存储后端相关
+var (
+	ErrLeaseNotFound = errors.New("lease not found")
+	ErrCompacted     = errors.New("revision has been compacted")
+)
+
+// 预定义错误 - 回调相关
+var (
+	ErrCallbackPanic  = errors.New("callback panicked")
+	ErrCallbackFailed = errors.New("callback failed")
+)
+
+// 预定义错误 - 死信相关
+var (
+	ErrDeadLetterWriteFailed = errors.New("dead letter write failed")
+	ErrDeadLetterNoWatcher   = errors.New("no active subscription for dead letter prefix")
 )
@@ -1,18 +1,65 @@
 package core
 
+import "context"
+
 // EventType 事件类型
 type EventType string
 
 const (
 	EventTypePut    EventType = "PUT"
 	EventTypeDelete EventType = "DELETE"
+	EventTypeReject EventType = "REJECT" // 新值反序列化或校验失败被拒绝，仅用于 Store
 )
 
 // WatchEvent 监听事件
+// 说明：
+//   - 快照回放的事件 Revision 为快照版本，其余元数据取自当前键值
+//   - DELETE 事件只有 Key、Revision 与 ModRevision（删除发生的版本）
+//   - 压缩后重新同步合成的 DELETE 事件只有 Key 与 Revision
 type WatchEvent struct {
-	Key       string    // 键
-	Value     []byte    // 值（原始字节）
-	EventType EventType // 事件类型
+	Key            string    // 键
+	Value          []byte    // 值（原始字节）
+	EventType      EventType // 事件类型
+	Revision       int64     // 事件对应的 etcd 版本，可用于检测乱序处理
+	CreateRevision int64     // 键创建时的版本
+	ModRevision    int64     // 键最后修改的版本
+	Version        int64     // 键自创建以来的修改次数，创建时为 1
+	Lease          int64     // 键绑定的租约 ID，0 表示无租约
+	PrevValue      []byte    // 变更前的值，仅在启用 core.WithPrevValue 时对监听事件提供
+	ctx            context.Context
+}
+
+// Context 返回事件的上下文
+// 说明：
+//   - 启用链路追踪时携带回调所在的 Span，可用于继续传播链路或记录日志
+//   - 未设置时返回 context.Background()
+func (e *WatchEvent) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// WithContext 返回使用 ctx 的事件浅拷贝
+func (e *WatchEvent) WithContext(ctx context.Context) *WatchEvent {
+	event := *e
+	event.ctx = ctx
+	return &event
+}
+
+// IsCreate 是否为创建键的 PUT 事件
+func (e *WatchEvent) IsCreate() bool {
+	return e.EventType.IsPut() && e.Version == 1
+}
+
+// IsModify 是否为修改已有键的 PUT 事件
+func (e *WatchEvent) IsModify() bool {
+	return e.EventType.IsPut() && e.Version > 1
+}
+
+// IsDelete 是否为 DELETE 事件
+func (e *WatchEvent) IsDelete() bool {
+	return e.EventType.IsDelete()
 }
 
 // String 返回事件类型的字符串表示
@@ -29,3 +76,40 @@ func (e EventType) IsPut() bool {
 func (e EventType) IsDelete() bool {
 	return e == EventTypeDelete
 }
+
+// IsReject 是否为 REJECT 事件
+func (e EventType) IsReject() bool {
+	return e == EventTypeReject
+}
+
+// ConfigChange 强类型缓存的配置变更
+// 说明：
+//   - Old 与 New 为缓存中的实例（指向结构体的指针），只读，不要修改
+//   - REJECT 事件的 Old 为保留在缓存中的最后有效实例，New 为被拒绝的实例（反序列化失败时为 nil），缓存不变
+type ConfigChange struct {
+	Key       string    // 配置键
+	EventType EventType // 事件类型
+	Old       any       // 变更前的实例，新增时为 nil
+	New       any       // 变更后的实例，删除时为 nil
+	Revision  int64     // 变更对应的 etcd 版本，添加监听器时回放的已有配置为 0
+	Err       error     // 拒绝原因，仅 REJECT 事件，包装 ErrUnmarshalFailed 或 ErrValidationFailed
+	ctx       context.Context
+}
+
+// Context 返回变更的上下文
+// 说明：
+//   - 启用链路追踪时携带回调所在的 Span，可用于继续传播链路或记录日志
+//   - 未设置时返回 context.Background()
+func (c *ConfigChange) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// WithContext 返回使用 ctx 的变更浅拷贝
+func (c *ConfigChange) WithContext(ctx context.Context) *ConfigChange {
+	change := *c
+	change.ctx = ctx
+	return &change
+}
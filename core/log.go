@@ -2,22 +2,152 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"strings"
+)
+
+// Level 日志级别
+type Level int
 
-	"github.com/zeromicro/go-zero/core/logx"
+const (
+	LevelDebug Level = iota // 调试，逐条配置的存储与删除等高频日志
+	LevelInfo               // 常规，订阅、注册、状态变化等
+	LevelError              // 错误
 )
 
+// LogCallerSkip 调用点到 Logger.Log 之间的调用层数
+// 说明：
+//   - 适配器据此跳过引擎内部的调用栈，使日志记录的调用位置为引擎中的调用点
+const LogCallerSkip = 2
+
+// String 返回日志级别的字符串表示
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// ParseLevel 解析日志级别
+// 参数：
+//   - s: debug、info 或 error，不区分大小写，为空时为 info
+//
+// 返回：
+//   - Level: 日志级别
+//   - error: 未知级别时返回 ErrInvalidConfig
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("%w: 未知的日志级别 %q", ErrInvalidConfig, s)
+	}
+}
+
+// LogField 日志字段
+type LogField struct {
+	Key   string
+	Value any
+}
+
+// Field 创建日志字段
+func Field(key string, value any) LogField {
+	return LogField{Key: key, Value: value}
+}
+
+// Logger 日志输出
+// 说明：
+//   - 引擎只在级别满足模块配置时调用 Log，实现无需再次过滤
+//   - fields 已包含 service、pod、module、operation 字段
+//   - 实现必须是并发安全的
+//   - logger 包提供 logx、log/slog 与 zap 的适配器
+type Logger interface {
+	Log(ctx context.Context, level Level, msg string, fields ...LogField)
+}
+
 // LogContext 日志上下文
+// 说明：
+//   - Logger 为 nil 时不输出日志
+//   - 模块级别优先于 Level，模块包括 store、watcher、stream
 type LogContext struct {
-	PodName     string
-	ServiceName string
-}
-
-// WithModule 创建带模块和操作的日志记录器
-func (c *LogContext) WithModule(module, operation string) logx.Logger {
-	return logx.WithContext(context.Background()).WithFields(
-		logx.Field("service", c.ServiceName),
-		logx.Field("pod", c.PodName),
-		logx.Field("module", module),
-		logx.Field("operation", operation),
-	)
+	PodName      string
+	ServiceName  string
+	Logger       Logger
+	Level        Level            // 默认级别
+	ModuleLevels map[string]Level // 模块 -> 级别
+}
+
+// WithModule 创建带模块和操作的日志记录
+func (c *LogContext) WithModule(module, operation string) *LogEntry {
+	return c.WithContext(context.Background(), module, operation)
+}
+
+// WithContext 创建带上下文、模块和操作的日志记录
+// 说明：
+//   - ctx 携带 Span 时由 Logger 决定是否附带链路字段（logx 与内置适配器均会附带）
+func (c *LogContext) WithContext(ctx context.Context, module, operation string) *LogEntry {
+	level, ok := c.ModuleLevels[module]
+	if !ok {
+		level = c.Level
+	}
+
+	return &LogEntry{
+		logger: c.Logger,
+		ctx:    ctx,
+		level:  level,
+		fields: []LogField{
+			Field("service", c.ServiceName),
+			Field("pod", c.PodName),
+			Field("module", module),
+			Field("operation", operation),
+		},
+	}
+}
+
+// LogEntry 带模块、操作与字段的日志记录
+type LogEntry struct {
+	logger Logger
+	ctx    context.Context
+	level  Level // 最低输出级别
+	fields []LogField
+}
+
+// WithFields 返回附加字段后的日志记录
+func (e *LogEntry) WithFields(fields ...LogField) *LogEntry {
+	entry := *e
+	entry.fields = append(e.fields[:len(e.fields):len(e.fields)], fields...)
+	return &entry
+}
+
+// Debug 输出调试日志
+func (e *LogEntry) Debug(msg string) {
+	e.log(LevelDebug, msg)
+}
+
+// Info 输出常规日志
+func (e *LogEntry) Info(msg string) {
+	e.log(LevelInfo, msg)
+}
+
+// Error 输出错误日志
+func (e *LogEntry) Error(msg string) {
+	e.log(LevelError, msg)
+}
+
+// log 按级别过滤后输出
+func (e *LogEntry) log(level Level, msg string) {
+	if e.logger == nil || level < e.level {
+		return
+	}
+	e.logger.Log(e.ctx, level, msg, e.fields...)
 }
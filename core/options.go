@@ -0,0 +1,167 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// OverflowPolicy 订阅队列满时的处理策略
+type OverflowPolicy string
+
+const (
+	OverflowBlock      OverflowPolicy = "block"       // 阻塞投递方，直到队列有空位
+	OverflowDropOldest OverflowPolicy = "drop_oldest" // 丢弃最早排队的事件
+	OverflowCoalesce   OverflowPolicy = "coalesce"    // 同一键只保留最新的事件，不同键占满队列时阻塞
+)
+
+// WatchOptions 订阅选项
+type WatchOptions struct {
+	Checkpoint     CheckpointStore // 检查点存储（可为 nil）
+	CheckpointName string          // 检查点名称，为空时为订阅的键或前缀
+	PrevValue      bool            // 是否携带变更前的值
+	QueueSize      int             // 订阅队列容量，0 表示回调在监听协程中同步执行
+	QueuePolicy    OverflowPolicy  // 订阅队列满时的处理策略
+	Workers        int             // 并发处理事件的工作协程数，不大于 1 时按顺序处理
+	Retry          RetryPolicy     // 回调失败的重试策略
+	DeadLetter     string          // 死信前缀，为空时重试耗尽后不写入死信
+}
+
+// RetryPolicy 回调失败的重试策略
+// 说明：
+//   - 重试等待从 InitialBackoff 开始逐次翻倍，不超过 MaxBackoff，实际等待在其 [1/2, 1] 区间内随机
+type RetryPolicy struct {
+	MaxAttempts    int           // 最大尝试次数（含首次），不大于 1 时不重试
+	InitialBackoff time.Duration // 首次重试前的等待，为 0 时为 100ms
+	MaxBackoff     time.Duration // 最长等待，为 0 时为 10s
+}
+
+// DefaultWorkerQueueSize 启用工作协程且未设置 WithQueue 时每个工作协程的队列容量
+const DefaultWorkerQueueSize = 256
+
+// WatchOption 订阅选项函数
+type WatchOption func(*WatchOptions)
+
+// NewWatchOptions 应用订阅选项
+func NewWatchOptions(opts ...WatchOption) *WatchOptions {
+	options := &WatchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithCheckpoint 启用检查点
+// 参数：
+//   - store: 检查点存储，默认以订阅的键或前缀作为检查点名称
+//
+// 说明：
+//   - 一次监听响应的事件全部回调成功后保存其中的最高版本，同一事务的事件不会被拆开
+//   - 回调失败后检查点停在失败版本之前，重启后从该版本重新回放
+//   - 失败的事件补写死信或重新全量同步后，检查点恢复推进
+//   - 重启后从检查点版本回放期间的历史变更，不再回放当前所有值
+//   - 检查点版本已被压缩时回退为全量同步
+//   - 同一前缀的多个订阅共用检查点存储时，需要用 WithCheckpointName 区分
+func WithCheckpoint(store CheckpointStore) WatchOption {
+	return func(o *WatchOptions) {
+		o.Checkpoint = store
+	}
+}
+
+// WithCheckpointName 指定检查点名称
+// 参数：
+//   - name: 检查点名称，为空时为订阅的键或前缀
+//
+// 说明：
+//   - 仅在启用 WithCheckpoint 时生效
+func WithCheckpointName(name string) WatchOption {
+	return func(o *WatchOptions) {
+		o.CheckpointName = name
+	}
+}
+
+// WithPrevValue 为监听事件携带变更前的值
+// 说明：
+//   - 基于 etcd 的 WithPrevKV，PUT 与 DELETE 事件的 PrevValue 为变更前的值
+//   - 会增加监听流量，仅在需要时启用
+func WithPrevValue() WatchOption {
+	return func(o *WatchOptions) {
+		o.PrevValue = true
+	}
+}
+
+// WithQueue 为订阅启用独立的有界队列与回调协程
+// 参数：
+//   - size: 队列容量，不大于 0 时不启用队列
+//   - policy: 队列满时的处理策略，为空时为 OverflowBlock
+//
+// 说明：
+//   - 回调不再阻塞监听协程与其他订阅，同一订阅的事件仍按顺序处理
+//   - OverflowDropOldest 与 OverflowCoalesce 会跳过事件，跳过的事件不执行回调
+//   - 启用检查点时，检查点推进到所有已接收事件都处理完成（或被跳过）的最高版本
+//   - 取消订阅或关闭引擎时丢弃尚未处理的事件
+//   - 队列深度与跳过的事件数通过 Subscription.Stats 获取
+func WithQueue(size int, policy OverflowPolicy) WatchOption {
+	return func(o *WatchOptions) {
+		o.QueueSize = size
+		o.QueuePolicy = policy
+	}
+}
+
+// WithWorkers 以 n 个工作协程并发处理事件
+// 参数：
+//   - n: 工作协程数，不大于 1 时不启用
+//
+// 说明：
+//   - 事件按键哈希分配到工作协程，同一键的事件按版本顺序处理，不同键并发处理
+//   - 每个工作协程拥有独立的队列，容量与策略由 WithQueue 设置，未设置时为 DefaultWorkerQueueSize 与 OverflowBlock
+//   - 回调需要是并发安全的
+//   - 启用检查点时，检查点推进到所有已接收事件都处理完成的最高版本，某个键处理缓慢会推迟检查点
+//   - 队列统计为所有工作协程队列之和
+func WithWorkers(n int) WatchOption {
+	return func(o *WatchOptions) {
+		o.Workers = n
+	}
+}
+
+// WithRetry 回调返回错误或 panic 时按策略重试
+// 参数：
+//   - policy: 重试策略
+//
+// 说明：
+//   - 重试在处理该事件的协程中进行：未启用队列时阻塞后续事件，启用 WithWorkers 时只阻塞同一工作协程的事件
+//   - 等待重试期间取消订阅或关闭引擎时放弃该事件，不写入死信
+func WithRetry(policy RetryPolicy) WatchOption {
+	return func(o *WatchOptions) {
+		o.Retry = policy
+	}
+}
+
+// WithDeadLetter 重试耗尽后将事件写入死信前缀
+// 参数：
+//   - prefix: 死信前缀，如 "/deadletters/my-service/orders/"
+//
+// 说明：
+//   - 写入死信后视为处理完成，检查点照常推进
+//   - 死信前缀不能位于同一引擎内任何 Watch 订阅的监听前缀之下，同一引擎内每个订阅使用独立的死信前缀
+//   - 通过 Engine.ListDeadLetters、RedriveDeadLetters、PurgeDeadLetters 查看、重放与清除
+func WithDeadLetter(prefix string) WatchOption {
+	return func(o *WatchOptions) {
+		o.DeadLetter = prefix
+	}
+}
+
+// Validate 校验订阅选项
+// 返回：
+//   - error: 队列策略未知或重试等待为负数时返回 ErrInvalidConfig
+func (o *WatchOptions) Validate() error {
+	switch o.QueuePolicy {
+	case "", OverflowBlock, OverflowDropOldest, OverflowCoalesce:
+	default:
+		return fmt.Errorf("%w: 未知的队列策略 %q", ErrInvalidConfig, o.QueuePolicy)
+	}
+
+	if o.Retry.InitialBackoff < 0 || o.Retry.MaxBackoff < 0 {
+		return fmt.Errorf("%w: 重试等待不能为负数", ErrInvalidConfig)
+	}
+	return nil
+}
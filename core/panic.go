@@ -0,0 +1,42 @@
+package core
+
+import "fmt"
+
+// PanicError 回调 panic 转换的错误
+// 说明：
+//   - errors.Is(err, ErrCallbackPanic) 为 true
+//   - 回调 panic 后引擎继续处理其他键与订阅者
+type PanicError struct {
+	Key   string // 触发 panic 的事件键
+	Value any    // recover() 返回的值
+	Stack []byte // panic 时的调用栈
+}
+
+// Error 返回错误描述
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v: key=%s: %v", ErrCallbackPanic, e.Key, e.Value)
+}
+
+// Unwrap 返回 ErrCallbackPanic
+func (e *PanicError) Unwrap() error {
+	return ErrCallbackPanic
+}
+
+// CallbackError Watch 回调返回的错误
+// 说明：
+//   - errors.Is(err, ErrCallbackFailed) 为 true，同时可匹配回调返回的原始错误
+type CallbackError struct {
+	Key      string // 事件键
+	Revision int64  // 事件版本
+	Err      error  // 回调返回的错误
+}
+
+// Error 返回错误描述
+func (e *CallbackError) Error() string {
+	return fmt.Sprintf("%v: key=%s revision=%d: %v", ErrCallbackFailed, e.Key, e.Revision, e.Err)
+}
+
+// Unwrap 返回 ErrCallbackFailed 与回调返回的错误
+func (e *CallbackError) Unwrap() []error {
+	return []error{ErrCallbackFailed, e.Err}
+}
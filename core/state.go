@@ -0,0 +1,16 @@
+package core
+
+// WatchState 监听状态
+type WatchState string
+
+const (
+	WatchStateSyncing  WatchState = "SYNCING"  // 正在全量同步前缀
+	WatchStateWatching WatchState = "WATCHING" // 监听已建立
+	WatchStateBackoff  WatchState = "BACKOFF"  // 监听中断，等待重连
+	WatchStateStopped  WatchState = "STOPPED"  // 监听已停止
+)
+
+// String 返回监听状态的字符串表示
+func (s WatchState) String() string {
+	return string(s)
+}
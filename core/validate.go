@@ -0,0 +1,11 @@
+package core
+
+// Validator 可自校验的配置类型
+// 绑定的结构体（指针）实现该接口时，Store 在缓存与写入前调用 Validate
+type Validator interface {
+	Validate() error
+}
+
+// ValidateFunc 配置校验函数
+// 参数 config 为指向绑定结构体的指针，返回非 nil 表示配置无效
+type ValidateFunc func(config any) error
@@ -7,3 +7,51 @@ type WatchCallback func(event *WatchEvent) error
 // PrefixWatchCallback 前缀监听回调函数类型
 // 用于处理某个前缀下的键值变更事件
 type PrefixWatchCallback func(key string, eventType EventType)
+
+// ConfigWatchCallback 配置变更回调函数类型
+// 用于处理某个前缀下强类型缓存的变更，携带变更前后的实例
+type ConfigWatchCallback func(change *ConfigChange)
+
+// WatchStateCallback 监听状态回调函数类型
+// 用于观察 Watcher 与 Store 管理的每个监听的状态变化，err 为进入该状态的原因
+type WatchStateCallback func(prefix string, state WatchState, err error)
+
+// ErrorCallback 错误回调函数类型
+// 用于接收回调 panic 转换的 *PanicError 与 Watch 回调返回错误转换的 *CallbackError，prefix 为订阅的键或前缀
+type ErrorCallback func(prefix string, err error)
+
+// Subscription 订阅句柄
+// 由 Watch 和 AddPrefixWatcher 返回，用于取消订阅和观察订阅状态
+type Subscription interface {
+	// Unsubscribe 取消订阅
+	// 说明：
+	//   - 可重复调用，可在回调内部调用
+	//   - 不等待正在执行的回调，需要等待时使用 Done()
+	Unsubscribe()
+
+	// Done 订阅结束后关闭的通道
+	// 说明：
+	//   - 关闭时不再有该订阅的回调在执行
+	Done() <-chan struct{}
+
+	// Err 订阅结束的原因
+	// 返回：
+	//   - nil: 订阅仍在运行，Done() 关闭前始终返回 nil
+	//   - ErrWatchCanceled: 调用了 Unsubscribe
+	//   - ErrConnectionClosed: 引擎已关闭
+	//   - 其他: 监听失败的原因
+	Err() error
+
+	// Stats 订阅队列统计
+	// 说明：
+	//   - 未启用 WithQueue 时返回零值
+	Stats() QueueStats
+}
+
+// QueueStats 订阅队列统计
+type QueueStats struct {
+	Capacity  int    // 队列容量，0 表示未启用队列
+	Depth     int    // 当前排队的事件数
+	Dropped   uint64 // 因 OverflowDropOldest 丢弃的事件数
+	Coalesced uint64 // 因 OverflowCoalesce 被合并的事件数
+}
@@ -2,9 +2,29 @@ package engine
 
 import "github.com/rezeropoint/etcdtrigger/v2/core"
 
+// StartupPolicy 启动策略
+type StartupPolicy string
+
+const (
+	// StartupDegraded 降级启动：预加载配置加载失败时仍创建引擎，失败的配置在后台重试
+	StartupDegraded StartupPolicy = "degraded"
+	// StartupFailFast 快速失败：任一预加载配置加载失败时 New 返回错误
+	StartupFailFast StartupPolicy = "failfast"
+)
+
 // Config 引擎配置
 type Config struct {
-	PodName     string             `json:",optional"` // Pod 标识（日志用）
-	ServiceName string             `json:",optional"` // 服务名称（日志用）
-	Configs     []core.WatchConfig `json:",optional"` // 预加载配置（强类型缓存用）
+	PodName      string                  `json:",optional"`                                   // Pod 标识（日志与分层配置用）
+	ServiceName  string                  `json:",optional"`                                   // 服务名称（日志与分层配置用）
+	Configs      []core.WatchConfig      `json:",optional"`                                   // 预加载配置（强类型缓存用）
+	Startup      StartupPolicy           `json:",default=degraded,options=degraded|failfast"` // 启动策略
+	OnWatchState core.WatchStateCallback `json:"-"`                                           // 监听状态回调（同步、监听、退避、停止）
+	OnError      core.ErrorCallback      `json:"-"`                                           // 回调出错的错误回调，接收 *core.PanicError 与 *core.CallbackError
+	SnapshotDir  string                  `json:",optional"`                                   // 本地快照目录，etcd 不可达时从快照启动（为空时不启用），监听变更最多延迟 1 秒落盘
+	Metrics      bool                    `json:",optional"`                                   // 是否记录 Prometheus 指标（需开启 go-zero 的 Prometheus 上报）
+	Tracing      bool                    `json:",optional"`                                   // 是否启用从写入到回调的链路追踪
+	TracePrefix  string                  `json:",optional"`                                   // 链路上下文旁路键前缀（为空时为 /__etcdtrigger/trace），不应落在任何监听前缀之下
+	Logger       core.Logger             `json:"-"`                                           // 日志输出（为 nil 时使用 logger.NewLogx()）
+	LogLevel     string                  `json:",optional,options=debug|info|error"`          // 默认日志级别（为空时为 info）
+	LogLevels    map[string]string       `json:",optional"`                                   // 模块日志级别（store、watcher、stream），优先于 LogLevel
 }
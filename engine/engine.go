@@ -1,8 +1,8 @@
 // Package engine 提供 etcd 配置管理的统一接口层。
 //
-// Engine 是对外暴露的核心接口，组合了两种功能模式：
+// Engine 是对外暴露的核心接口，通过 core.Backend 访问存储（默认 etcd，测试可用 backend.NewMemory），组合了两种功能模式：
 //   - Watcher: 原始回调监听，处理字节数组数据
-//   - Store: 强类型配置缓存，自动 JSON 序列化/反序列化
+//   - Store: 强类型配置缓存，按路径配置的编解码器自动序列化/反序列化（默认 JSON）
 //
 // 使用示例：
 //
@@ -14,10 +14,17 @@
 //	defer etcdClient.Close()
 //
 //	// 创建 Engine
-//	eng := engine.NewEngine(etcdClient, &engine.Config{
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	eng, err := engine.New(ctx, etcdClient, &engine.Config{
 //	    PodName:     "my-pod",
 //	    ServiceName: "my-service",
+//	    Startup:     engine.StartupFailFast,
 //	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer eng.Close(context.Background())
 //
 //	// 使用 Store 功能
 //	var cfg MyConfig
@@ -28,7 +35,10 @@ package engine
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
 	"github.com/rezeropoint/etcdtrigger/v2/core"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
@@ -43,12 +53,15 @@ type Engine interface {
 	// 参数：
 	//   - key: 监听的键或前缀
 	//   - callback: 配置变更时的回调函数
+	//   - opts: 订阅选项，如 core.WithCheckpoint
 	// 返回：
+	//   - core.Subscription: 订阅句柄，用于取消订阅
 	//   - error: 订阅失败时返回错误
 	// 说明：
 	//   - 支持前缀匹配，会先触发当前已存在的值
 	//   - 后续变更会异步触发回调
-	Watch(key string, callback core.WatchCallback) error
+	//   - 启用检查点且检查点存在时，改为回放检查点之后的历史变更
+	Watch(key string, callback core.WatchCallback, opts ...core.WatchOption) (core.Subscription, error)
 
 	// WatchPut 写入原始字节数据到 etcd
 	// 参数：
@@ -73,6 +86,44 @@ type Engine interface {
 	//   - error: 获取失败或 key 不存在时返回错误
 	WatchGet(key string) ([]byte, error)
 
+	// ListDeadLetters 列出死信前缀下的死信
+	// 参数：
+	//   - ctx: 上下文
+	//   - prefix: core.WithDeadLetter 设置的死信前缀
+	// 返回：
+	//   - []*core.DeadLetter: 按事件版本排序的死信，ID 为死信前缀下的键名
+	//   - error: 读取失败时返回错误
+	// 说明：
+	//   - 直接读取 etcd，不要求当前进程存在对应的订阅
+	ListDeadLetters(ctx context.Context, prefix string) ([]*core.DeadLetter, error)
+
+	// RedriveDeadLetters 将死信重新交给订阅回调处理
+	// 参数：
+	//   - ctx: 上下文，取消时停止重放
+	//   - prefix: core.WithDeadLetter 设置的死信前缀
+	//   - ids: 要重放的死信 ID，为空时重放全部
+	// 返回：
+	//   - int: 处理成功并已删除的死信数
+	//   - error: 不存在使用该死信前缀的订阅时返回 core.ErrDeadLetterNoWatcher，部分死信再次失败时返回合并的 *core.CallbackError
+	// 说明：
+	//   - 需要当前引擎中存在使用该死信前缀的 Watch 订阅，按订阅的重试策略在调用方协程中依次执行回调
+	//   - 再次失败的死信保留在原位置，累加尝试次数并更新错误
+	//   - 重放的回调可能与订阅正在处理的新事件并发执行
+	RedriveDeadLetters(ctx context.Context, prefix string, ids ...string) (int, error)
+
+	// PurgeDeadLetters 删除死信
+	// 参数：
+	//   - ctx: 上下文
+	//   - prefix: core.WithDeadLetter 设置的死信前缀
+	//   - ids: 要删除的死信 ID，为空时删除全部
+	// 返回：
+	//   - int: 删除的死信数
+	//   - error: 读取或删除失败时返回错误
+	// 说明：
+	//   - 与 ListDeadLetters 相同，直接读取 etcd，不要求当前进程存在对应的订阅
+	//   - 逐条删除 ListDeadLetters 返回的死信，死信前缀下的其他键保持不变
+	PurgeDeadLetters(ctx context.Context, prefix string, ids ...string) (int, error)
+
 	// GetConfig 从内存缓存获取强类型配置
 	// 参数：
 	//   - key: 配置键名
@@ -95,9 +146,13 @@ type Engine interface {
 	// 参数：
 	//   - ctx: 上下文
 	//   - key: 配置键名
-	//   - config: 配置对象，会自动 JSON 序列化
+	//   - config: 配置对象，按与 key 最长匹配的预加载路径的编解码器序列化，未匹配时使用 JSON
 	// 返回：
-	//   - error: 序列化或写入失败时返回错误
+	//   - error: 校验、序列化或写入失败时返回错误
+	// 说明：
+	//   - 写入前执行 config 自身的 Validate 方法（实现 core.Validator 时）与匹配路径的 Validate 校验函数
+	//   - 校验失败时不写入，返回包装 core.ErrValidationFailed 的错误
+	//   - config 可以是结构体值或指针，与匹配路径绑定的类型不一致时返回 core.ErrInvalidConfig
 	PutConfig(ctx context.Context, key string, config any) error
 
 	// DeleteConfig 从 etcd 删除配置
@@ -108,30 +163,194 @@ type Engine interface {
 	//   - error: 删除失败时返回错误
 	DeleteConfig(ctx context.Context, key string) error
 
+	// RegisterConfig 运行时注册预加载配置
+	// 参数：
+	//   - ctx: 控制初始加载的超时
+	//   - cfg: 预加载配置，Struct 必须是指向结构体的指针
+	// 返回：
+	//   - error: 路径已注册、参数无效或初始加载失败时返回错误
+	// 说明：
+	//   - 同步加载路径下的现有配置后返回，随后持续监听变更
+	//   - 新加载的配置会触发匹配的前缀监听器
+	RegisterConfig(ctx context.Context, cfg core.WatchConfig) error
+
+	// UnregisterConfig 注销预加载配置
+	// 参数：
+	//   - path: 注册时使用的路径
+	// 返回：
+	//   - error: 路径未注册时返回 core.ErrConfigNotFound
+	// 说明：
+	//   - 停止该路径的监听并清除其缓存
+	//   - 被清除且不再被其他路径缓存的键会以 DELETE 事件通知前缀监听器
+	//   - 等待该路径正在应用的变更结束后才清除缓存，不要在该路径触发的前缀监听器回调中调用
+	UnregisterConfig(path string) error
+
 	// AddPrefixWatcher 添加前缀监听器
 	// 参数：
 	//   - prefix: 要监听的键前缀
 	//   - callback: 配置变更时的回调函数
+	//   - opts: 订阅选项，仅 core.WithQueue 适用
+	// 返回：
+	//   - core.Subscription: 订阅句柄，取消后监听器被移除
 	// 说明：
 	//   - 添加时会立即触发已存在配置的回调
 	//   - 后续匹配前缀的配置变更都会触发回调
-	AddPrefixWatcher(prefix string, callback core.PrefixWatchCallback)
+	//   - 同一前缀可添加多个监听器，互不影响
+	//   - 新值反序列化或校验失败时以 core.EventTypeReject 通知，缓存保持不变
+	//   - 启用 core.WithQueue 时回调在监听器独立的协程中执行，慢回调不阻塞缓存更新与其他监听器
+	AddPrefixWatcher(prefix string, callback core.PrefixWatchCallback, opts ...core.WatchOption) core.Subscription
+
+	// AddConfigWatcher 添加携带变更前后实例的前缀监听器
+	// 参数：
+	//   - prefix: 要监听的键前缀
+	//   - callback: 配置变更时的回调函数
+	//   - opts: 订阅选项，仅 core.WithQueue 适用
+	// 返回：
+	//   - core.Subscription: 订阅句柄，取消后监听器被移除
+	// 说明：
+	//   - 变更前后的实例取自缓存替换前后，无需再调用 GetConfig
+	//   - 添加时会立即以 Old 为 nil 触发已存在配置的回调
+	//   - 反序列化或校验失败的值不会进入缓存，以 REJECT 事件通知，Old 为保留的最后有效实例，Err 为拒绝原因
+	//   - 使用 OverflowCoalesce 时同一键排队的变更合并为一次，Old 为合并前最早的实例
+	AddConfigWatcher(prefix string, callback core.ConfigWatchCallback, opts ...core.WatchOption) core.Subscription
+
+	// Ready 返回就绪通道
+	// 返回：
+	//   - <-chan struct{}: Config.Configs 中的配置全部完成初始加载后关闭
+	// 说明：
+	//   - 降级启动时，加载失败的配置在后台重试成功后才会就绪
+	//   - 从本地快照恢复的配置立即就绪，可通过 Stale 判断是否已与 etcd 对齐
+	//   - 通过 RegisterConfig 注册的配置与 Watch 订阅不影响就绪状态
+	Ready() <-chan struct{}
+
+	// Stale 是否仍在使用尚未与 etcd 对齐的快照数据
+	// 返回：
+	//   - bool: 启用 SnapshotDir 且有预加载配置从本地快照恢复、尚未与 etcd 对齐时为 true
+	// 说明：
+	//   - 对齐前 GetConfig 返回快照中的配置，可能落后于 etcd
+	//   - 监听追上 etcd 当前版本或重新全量同步后变为 false
+	Stale() bool
+
+	// WaitReady 等待预加载配置全部完成初始加载
+	// 参数：
+	//   - ctx: 控制等待的超时
+	// 返回：
+	//   - error: ctx 到期前未就绪时返回 ctx.Err()
+	WaitReady(ctx context.Context) error
 
 	// Client 返回底层的 etcd 客户端
 	// 返回：
-	//   - *clientv3.Client: etcd 客户端实例
+	//   - *clientv3.Client: etcd 客户端实例，通过 NewWithBackend 使用非 etcd 后端时为 nil
 	// 说明：
 	//   - 用于需要直接操作 etcd 的高级场景
 	//   - 客户端生命周期由调用方管理
 	Client() *clientv3.Client
+
+	// Backend 返回存储后端
+	// 返回：
+	//   - core.Backend: 存储后端，使用 etcd 客户端创建时为 *backend.Etcd
+	Backend() core.Backend
+
+	// Close 关闭引擎
+	// 参数：
+	//   - ctx: 控制等待回调结束的超时
+	// 返回：
+	//   - error: ctx 到期前仍有回调未结束时返回 ctx.Err()
+	// 说明：
+	//   - 取消 Watcher 与 Store 启动的所有监听
+	//   - 等待正在执行的回调结束
+	//   - 关闭后写入、删除、订阅等操作返回 core.ErrConnectionClosed
+	//   - 关闭后缓存读取仍返回最后的快照
+	//   - 不会关闭 etcd 客户端，客户端生命周期由调用方管理
+	Close(ctx context.Context) error
 }
 
-// NewEngine 创建新的 Engine
+// New 创建新的 Engine
 // 参数：
+//   - ctx: 控制预加载配置的初始加载
 //   - client: etcd 客户端（由调用方管理生命周期）
-//   - config: 引擎配置
+//   - config: 引擎配置，为 nil 时使用零值
+//
+// 返回：
+//   - Engine: 配置管理引擎实例
+//   - error: 参数无效，或 StartupFailFast 策略下预加载配置加载失败时返回错误
+//
+// 说明：
+//   - StartupDegraded（默认）: 加载失败的配置在后台重试，可通过 Ready/WaitReady 等待就绪
+//   - StartupFailFast: 任一预加载配置加载失败即返回错误，成功返回时已就绪
+//   - 配置 SnapshotDir 时，存在本地快照的配置从快照加载，不依赖 etcd 可达
+func New(ctx context.Context, client *clientv3.Client, config *Config) (Engine, error) {
+	if client == nil {
+		return nil, fmt.Errorf("%w: client 不能为空", core.ErrInvalidConfig)
+	}
+	return NewWithBackend(ctx, backend.NewEtcd(client), config)
+}
+
+// NewWithBackend 使用指定存储后端创建新的 Engine
+// 参数：
+//   - ctx: 控制预加载配置的初始加载
+//   - b: 存储后端，如 backend.NewMemory()
+//   - config: 引擎配置，为 nil 时使用零值
+//
 // 返回：
 //   - Engine: 配置管理引擎实例
+//   - error: 参数无效，或 StartupFailFast 策略下预加载配置加载失败时返回错误
+//
+// 说明：
+//   - 启动策略与 New 相同
+func NewWithBackend(ctx context.Context, b core.Backend, config *Config) (Engine, error) {
+	eng, err := newEngine(ctx, b, config)
+	if err != nil {
+		return nil, err
+	}
+	return eng, nil
+}
+
+// NewEngine 创建新的 Engine
+// 参数：
+//   - client: etcd 客户端（由调用方管理生命周期）
+//   - config: 引擎配置，为 nil 时使用零值
+//
+// 返回：
+//   - Engine: 配置管理引擎实例，client 为 nil 或配置无效时为 nil
+//
+// 说明：
+//   - 始终按 StartupDegraded 策略启动，初始加载最多等待 10 秒
+//
+// Deprecated: use New，New 返回创建失败的原因并支持 StartupFailFast 策略。
 func NewEngine(client *clientv3.Client, config *Config) Engine {
-	return newEngine(client, config)
+	if client == nil {
+		return nil
+	}
+	return NewEngineWithBackend(backend.NewEtcd(client), config)
+}
+
+// NewEngineWithBackend 使用指定存储后端创建新的 Engine
+// 参数：
+//   - b: 存储后端，如 backend.NewMemory()
+//   - config: 引擎配置，为 nil 时使用零值
+//
+// 返回：
+//   - Engine: 配置管理引擎实例，b 为 nil 或配置无效时为 nil
+//
+// 说明：
+//   - 与 NewEngine 相同，按 StartupDegraded 策略启动
+//   - 配合内存后端可以在纯 go test 中运行 Watcher 与 Store 的全部行为
+//
+// Deprecated: use NewWithBackend，NewWithBackend 返回创建失败的原因并支持 StartupFailFast 策略。
+func NewEngineWithBackend(b core.Backend, config *Config) Engine {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var degraded Config
+	if config != nil {
+		degraded = *config
+	}
+	degraded.Startup = StartupDegraded
+
+	eng, err := NewWithBackend(ctx, b, &degraded)
+	if err != nil {
+		return nil
+	}
+	return eng
 }
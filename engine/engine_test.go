@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/testutil"
+	"github.com/rezeropoint/etcdtrigger/v2/logger"
+)
+
+type appConfig struct {
+	Name string `json:"name"`
+}
+
+// flakyBackend down 为 true 时读取失败，模拟 etcd 不可用
+type flakyBackend struct {
+	*backend.Memory
+	down atomic.Bool
+}
+
+func (b *flakyBackend) Get(ctx context.Context, key string, prefix bool) (*core.GetResult, error) {
+	if b.down.Load() {
+		return nil, errors.New("etcd 不可用")
+	}
+	return b.Memory.Get(ctx, key, prefix)
+}
+
+// discard 丢弃引擎日志
+var discard = logger.NewSlog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+// closeEngine 测试结束时关闭引擎
+func closeEngine(t *testing.T, eng Engine) {
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.Timeout)
+		defer cancel()
+		if err := eng.Close(ctx); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
+
+func TestNewInvalidArguments(t *testing.T) {
+	ctx := context.Background()
+	mem := backend.NewMemory()
+
+	tests := []struct {
+		name string
+		new  func() (Engine, error)
+	}{
+		{name: "New nil client", new: func() (Engine, error) { return New(ctx, nil, &Config{}) }},
+		{name: "NewWithBackend nil backend", new: func() (Engine, error) { return NewWithBackend(ctx, nil, &Config{}) }},
+		{name: "unknown startup", new: func() (Engine, error) {
+			return NewWithBackend(ctx, mem, &Config{Startup: "eventually"})
+		}},
+		{name: "unknown log level", new: func() (Engine, error) {
+			return NewWithBackend(ctx, mem, &Config{LogLevel: "verbose"})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng, err := tt.new()
+			if !errors.Is(err, core.ErrInvalidConfig) || eng != nil {
+				t.Fatalf("= %v, %v, want nil, ErrInvalidConfig", eng, err)
+			}
+		})
+	}
+}
+
+func TestNewEngineInvalidArguments(t *testing.T) {
+	if eng := NewEngine(nil, nil); eng != nil {
+		t.Fatalf("NewEngine(nil) = %v, want nil", eng)
+	}
+	if eng := NewEngineWithBackend(nil, nil); eng != nil {
+		t.Fatalf("NewEngineWithBackend(nil) = %v, want nil", eng)
+	}
+	if eng := NewEngineWithBackend(backend.NewMemory(), &Config{LogLevel: "verbose"}); eng != nil {
+		t.Fatalf("NewEngineWithBackend(未知日志级别) = %v, want nil", eng)
+	}
+}
+
+func TestNewNilConfig(t *testing.T) {
+	for name, newEngine := range map[string]func(core.Backend) (Engine, error){
+		"NewWithBackend":       func(b core.Backend) (Engine, error) { return NewWithBackend(context.Background(), b, nil) },
+		"NewEngineWithBackend": func(b core.Backend) (Engine, error) { return NewEngineWithBackend(b, nil), nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			eng, err := newEngine(backend.NewMemory())
+			if err != nil {
+				t.Fatalf("nil config: %v", err)
+			}
+			closeEngine(t, eng)
+
+			ctx, cancel := context.WithTimeout(context.Background(), testutil.Timeout)
+			defer cancel()
+			if err := eng.WaitReady(ctx); err != nil {
+				t.Fatalf("没有预加载配置时 WaitReady: %v", err)
+			}
+		})
+	}
+}
+
+func TestStartupFailFast(t *testing.T) {
+	b := &flakyBackend{Memory: backend.NewMemory()}
+	b.down.Store(true)
+
+	eng, err := NewWithBackend(context.Background(), b, &Config{
+		Startup: StartupFailFast,
+		Logger:  discard,
+		Configs: []core.WatchConfig{{Path: "/app/", Struct: &appConfig{}}},
+	})
+	if err == nil || eng != nil {
+		t.Fatalf("NewWithBackend = %v, %v, want 加载失败的错误", eng, err)
+	}
+}
+
+func TestStartupDegradedReady(t *testing.T) {
+	ctx := context.Background()
+	b := &flakyBackend{Memory: backend.NewMemory()}
+	if _, err := b.Put(ctx, "/app/main", []byte(`{"name":"main"}`), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	b.down.Store(true)
+
+	eng, err := NewWithBackend(ctx, b, &Config{
+		Logger:  discard,
+		Configs: []core.WatchConfig{{Path: "/app/", Struct: &appConfig{}}},
+	})
+	if err != nil {
+		t.Fatalf("降级启动不应返回错误: %v", err)
+	}
+	closeEngine(t, eng)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := eng.WaitReady(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("etcd 不可用时 WaitReady = %v, want DeadlineExceeded", err)
+	}
+
+	b.down.Store(false)
+	waitCtx, cancel = context.WithTimeout(ctx, testutil.Timeout)
+	defer cancel()
+	if err := eng.WaitReady(waitCtx); err != nil {
+		t.Fatalf("恢复后 WaitReady: %v", err)
+	}
+
+	var got appConfig
+	if !eng.GetConfig("/app/main", &got) || got.Name != "main" {
+		t.Fatalf("GetConfig = %+v, want 已加载的配置", got)
+	}
+}
+
+func TestCloseWaitsForCallbacks(t *testing.T) {
+	eng, err := NewWithBackend(context.Background(), backend.NewMemory(), &Config{Logger: discard})
+	if err != nil {
+		t.Fatalf("NewWithBackend: %v", err)
+	}
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	var finished atomic.Bool
+	_, err = eng.Watch("/app/", func(event *core.WatchEvent) error {
+		if event.EventType != core.EventTypePut {
+			return nil
+		}
+		close(entered)
+		<-unblock
+		finished.Store(true)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if err := eng.WatchPut("/app/main", []byte("v1")); err != nil {
+		t.Fatalf("WatchPut: %v", err)
+	}
+
+	select {
+	case <-entered:
+	case <-time.After(testutil.Timeout):
+		t.Fatal("回调未执行")
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.Timeout)
+		defer cancel()
+		closed <- eng.Close(ctx)
+	}()
+
+	select {
+	case err := <-closed:
+		t.Fatalf("回调未结束时 Close 已返回: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(unblock)
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	case <-time.After(testutil.Timeout):
+		t.Fatal("回调结束后 Close 未返回")
+	}
+	if !finished.Load() {
+		t.Fatal("Close 在回调结束前返回")
+	}
+
+	if _, err := eng.Watch("/app/", func(*core.WatchEvent) error { return nil }); !errors.Is(err, core.ErrConnectionClosed) {
+		t.Fatalf("关闭后 Watch = %v, want ErrConnectionClosed", err)
+	}
+	if err := eng.WatchPut("/app/main", []byte("v2")); !errors.Is(err, core.ErrConnectionClosed) {
+		t.Fatalf("关闭后 WatchPut = %v, want ErrConnectionClosed", err)
+	}
+	if err := eng.RegisterConfig(context.Background(), core.WatchConfig{Path: "/app/", Struct: &appConfig{}}); !errors.Is(err, core.ErrConnectionClosed) {
+		t.Fatalf("关闭后 RegisterConfig = %v, want ErrConnectionClosed", err)
+	}
+	sub := eng.AddPrefixWatcher("/app/", func(string, core.EventType) {})
+	select {
+	case <-sub.Done():
+	case <-time.After(testutil.Timeout):
+		t.Fatal("关闭后 AddPrefixWatcher 返回的订阅未结束")
+	}
+	if err := sub.Err(); !errors.Is(err, core.ErrConnectionClosed) {
+		t.Fatalf("关闭后 AddPrefixWatcher 订阅 Err = %v, want ErrConnectionClosed", err)
+	}
+}
@@ -2,37 +2,117 @@ package engine
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
 	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/metrics"
 	"github.com/rezeropoint/etcdtrigger/v2/internal/store"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/tracing"
 	"github.com/rezeropoint/etcdtrigger/v2/internal/watcher"
+	"github.com/rezeropoint/etcdtrigger/v2/logger"
+	"github.com/zeromicro/go-zero/core/mr"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 // engine Engine 实现
 type engine struct {
-	client     *clientv3.Client
+	backend    core.Backend
 	watcherMgr watcher.Manager
 	storeMgr   store.Manager
 }
 
 // newEngine 创建 Engine 实例
-func newEngine(client *clientv3.Client, config *Config) *engine {
-	logCtx := &core.LogContext{
-		PodName:     config.PodName,
-		ServiceName: config.ServiceName,
+func newEngine(ctx context.Context, b core.Backend, config *Config) (*engine, error) {
+	if b == nil {
+		return nil, fmt.Errorf("%w: backend 不能为空", core.ErrInvalidConfig)
+	}
+	if config == nil {
+		config = &Config{}
+	}
+
+	switch config.Startup {
+	case "", StartupDegraded, StartupFailFast:
+	default:
+		return nil, fmt.Errorf("%w: 未知的启动策略 %q", core.ErrInvalidConfig, config.Startup)
+	}
+
+	logCtx, err := newLogContext(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		recorder *metrics.Metrics
+		tracer   *tracing.Tracer
+	)
+	if config.Metrics {
+		recorder = metrics.New()
+	}
+	if config.Tracing {
+		tracer = tracing.New(b, config.TracePrefix)
+	}
+
+	storeMgr, err := store.NewManager(ctx, b, logCtx, &store.Config{
+		Configs:      config.Configs,
+		FailFast:     config.Startup == StartupFailFast,
+		OnWatchState: config.OnWatchState,
+		SnapshotDir:  config.SnapshotDir,
+		Metrics:      recorder,
+		Tracer:       tracer,
+		OnError:      config.OnError,
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &engine{
-		client:     client,
-		watcherMgr: watcher.NewManager(client, logCtx, &watcher.Config{}),
-		storeMgr:   store.NewManager(client, logCtx, &store.Config{Configs: config.Configs}),
+		backend: b,
+		watcherMgr: watcher.NewManager(b, logCtx, &watcher.Config{
+			OnWatchState: config.OnWatchState,
+			Metrics:      recorder,
+			Tracer:       tracer,
+			OnError:      config.OnError,
+		}),
+		storeMgr: storeMgr,
+	}, nil
+}
+
+// newLogContext 根据引擎配置创建日志上下文
+// 返回：
+//   - error: 日志级别无法解析时返回 core.ErrInvalidConfig
+func newLogContext(config *Config) (*core.LogContext, error) {
+	level, err := core.ParseLevel(config.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleLevels := make(map[string]core.Level, len(config.LogLevels))
+	for module, name := range config.LogLevels {
+		moduleLevel, err := core.ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: module=%s", err, module)
+		}
+		moduleLevels[module] = moduleLevel
+	}
+
+	log := config.Logger
+	if log == nil {
+		log = logger.NewLogx()
 	}
+
+	return &core.LogContext{
+		PodName:      config.PodName,
+		ServiceName:  config.ServiceName,
+		Logger:       log,
+		Level:        level,
+		ModuleLevels: moduleLevels,
+	}, nil
 }
 
 // Watch 订阅配置变更（原始回调模式）
-func (e *engine) Watch(key string, callback core.WatchCallback) error {
-	return e.watcherMgr.Watch(key, callback)
+func (e *engine) Watch(key string, callback core.WatchCallback, opts ...core.WatchOption) (core.Subscription, error) {
+	return e.watcherMgr.Watch(key, callback, opts...)
 }
 
 // WatchPut 写入原始数据
@@ -50,6 +130,21 @@ func (e *engine) WatchGet(key string) ([]byte, error) {
 	return e.watcherMgr.WatchGet(key)
 }
 
+// ListDeadLetters 列出死信
+func (e *engine) ListDeadLetters(ctx context.Context, prefix string) ([]*core.DeadLetter, error) {
+	return e.watcherMgr.ListDeadLetters(ctx, prefix)
+}
+
+// RedriveDeadLetters 重放死信
+func (e *engine) RedriveDeadLetters(ctx context.Context, prefix string, ids ...string) (int, error) {
+	return e.watcherMgr.RedriveDeadLetters(ctx, prefix, ids...)
+}
+
+// PurgeDeadLetters 删除死信
+func (e *engine) PurgeDeadLetters(ctx context.Context, prefix string, ids ...string) (int, error) {
+	return e.watcherMgr.PurgeDeadLetters(ctx, prefix, ids...)
+}
+
 // GetConfig 从缓存获取配置（强类型）
 func (e *engine) GetConfig(key string, result any) bool {
 	return e.storeMgr.GetConfig(key, result)
@@ -70,12 +165,64 @@ func (e *engine) DeleteConfig(ctx context.Context, key string) error {
 	return e.storeMgr.DeleteConfig(ctx, key)
 }
 
+// RegisterConfig 运行时注册预加载配置
+func (e *engine) RegisterConfig(ctx context.Context, cfg core.WatchConfig) error {
+	return e.storeMgr.RegisterConfig(ctx, cfg)
+}
+
+// UnregisterConfig 注销预加载配置
+func (e *engine) UnregisterConfig(path string) error {
+	return e.storeMgr.UnregisterConfig(path)
+}
+
 // AddPrefixWatcher 添加前缀监听器
-func (e *engine) AddPrefixWatcher(prefix string, callback core.PrefixWatchCallback) {
-	e.storeMgr.AddPrefixWatcher(prefix, callback)
+func (e *engine) AddPrefixWatcher(prefix string, callback core.PrefixWatchCallback, opts ...core.WatchOption) core.Subscription {
+	return e.storeMgr.AddPrefixWatcher(prefix, callback, opts...)
+}
+
+// AddConfigWatcher 添加携带变更前后实例的前缀监听器
+func (e *engine) AddConfigWatcher(prefix string, callback core.ConfigWatchCallback, opts ...core.WatchOption) core.Subscription {
+	return e.storeMgr.AddConfigWatcher(prefix, callback, opts...)
+}
+
+// Ready 预加载配置全部完成初始加载后关闭的通道
+func (e *engine) Ready() <-chan struct{} {
+	return e.storeMgr.Ready()
 }
 
-// Client 返回底层 etcd 客户端
+// Stale 是否仍在使用尚未与 etcd 对齐的快照数据
+func (e *engine) Stale() bool {
+	return e.storeMgr.Stale()
+}
+
+// WaitReady 等待预加载配置全部完成初始加载
+func (e *engine) WaitReady(ctx context.Context) error {
+	select {
+	case <-e.storeMgr.Ready():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Client 返回底层 etcd 客户端，非 etcd 后端时返回 nil
 func (e *engine) Client() *clientv3.Client {
-	return e.client
+	if etcd, ok := e.backend.(*backend.Etcd); ok {
+		return etcd.Client()
+	}
+	return nil
+}
+
+// Backend 返回存储后端
+func (e *engine) Backend() core.Backend {
+	return e.backend
+}
+
+// Close 关闭引擎，并行关闭 Watcher 与 Store
+func (e *engine) Close(ctx context.Context) error {
+	return mr.Finish(func() error {
+		return e.watcherMgr.Close(ctx)
+	}, func() error {
+		return e.storeMgr.Close(ctx)
+	})
 }
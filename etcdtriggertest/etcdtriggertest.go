@@ -0,0 +1,176 @@
+// Package etcdtriggertest 提供集成测试辅助工具。
+//
+// 在临时目录与随机端口上启动嵌入式 etcd，并返回已就绪的 Engine，
+// 配合 ExpectEvent、EventuallyCached 断言异步结果，不再需要 time.Sleep：
+//
+//	func TestDatabaseConfig(t *testing.T) {
+//	    eng, srv := etcdtriggertest.NewEngine(t, &engine.Config{
+//	        Configs: []core.WatchConfig{store.Bind[DatabaseConfig]("/app/config/database/")},
+//	    })
+//
+//	    sub := etcdtriggertest.Watch(t, eng, "/app/config/")
+//	    srv.Put(t, "/app/config/database/main", `{"host":"localhost"}`)
+//	    etcdtriggertest.ExpectEvent(t, sub, "/app/config/database/main", core.EventTypePut, 5*time.Second)
+//
+//	    var db DatabaseConfig
+//	    etcdtriggertest.EventuallyCached(t, eng, "/app/config/database/main", &db)
+//	}
+//
+// 所有资源通过 t.Cleanup 在测试结束时释放。
+package etcdtriggertest
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/engine"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// DefaultTimeout 辅助函数的默认等待时长
+const DefaultTimeout = 10 * time.Second
+
+// Server 嵌入式 etcd 服务
+type Server struct {
+	Etcd     *embed.Etcd
+	Client   *clientv3.Client
+	Endpoint string // 客户端地址，如 http://127.0.0.1:23790
+}
+
+// StartEtcd 启动嵌入式 etcd
+// 参数：
+//   - t: 测试上下文，启动失败时终止测试
+//
+// 返回：
+//   - *Server: 已就绪的 etcd 服务与连接它的客户端
+//
+// 说明：
+//   - 数据目录为 t.TempDir()，客户端与 peer 端口随机分配
+//   - 测试结束时关闭客户端与服务
+func StartEtcd(t testing.TB) *Server {
+	t.Helper()
+
+	clientURL := localURL(t)
+	peerURL := localURL(t)
+
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	cfg.ListenClientUrls = []url.URL{clientURL}
+	cfg.AdvertiseClientUrls = []url.URL{clientURL}
+	cfg.ListenPeerUrls = []url.URL{peerURL}
+	cfg.AdvertisePeerUrls = []url.URL{peerURL}
+	cfg.InitialCluster = cfg.Name + "=" + peerURL.String()
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatalf("启动 etcd 失败: %v", err)
+	}
+	t.Cleanup(e.Close)
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(DefaultTimeout):
+		e.Server.Stop()
+		t.Fatalf("etcd 未在 %v 内就绪", DefaultTimeout)
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{clientURL.String()},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("创建 etcd 客户端失败: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return &Server{
+		Etcd:     e,
+		Client:   client,
+		Endpoint: clientURL.String(),
+	}
+}
+
+// NewEngine 启动嵌入式 etcd 并创建已就绪的 Engine
+// 参数：
+//   - t: 测试上下文
+//   - config: 引擎配置，为 nil 时使用空配置
+//
+// 返回：
+//   - engine.Engine: 预加载配置已完成初始加载的引擎
+//   - *Server: 引擎使用的 etcd 服务
+//
+// 说明：
+//   - 创建或等待就绪失败时终止测试
+//   - 测试结束时关闭引擎，先于 etcd 关闭
+func NewEngine(t testing.TB, config *engine.Config) (engine.Engine, *Server) {
+	t.Helper()
+
+	srv := StartEtcd(t)
+	if config == nil {
+		config = &engine.Config{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	eng, err := engine.New(ctx, srv.Client, config)
+	if err != nil {
+		t.Fatalf("创建引擎失败: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancel()
+		_ = eng.Close(ctx)
+	})
+
+	if err := eng.WaitReady(ctx); err != nil {
+		t.Fatalf("引擎未就绪: %v", err)
+	}
+
+	return eng, srv
+}
+
+// Put 直接写入 etcd，失败时终止测试
+func (s *Server) Put(t testing.TB, key, value string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	if _, err := s.Client.Put(ctx, key, value); err != nil {
+		t.Fatalf("写入 %s 失败: %v", key, err)
+	}
+}
+
+// Delete 直接从 etcd 删除，失败时终止测试
+func (s *Server) Delete(t testing.TB, key string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	if _, err := s.Client.Delete(ctx, key); err != nil {
+		t.Fatalf("删除 %s 失败: %v", key, err)
+	}
+}
+
+// localURL 分配本地随机端口
+func localURL(t testing.TB) url.URL {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("分配端口失败: %v", err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	return url.URL{Scheme: "http", Host: addr}
+}
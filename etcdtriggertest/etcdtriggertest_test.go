@@ -0,0 +1,70 @@
+package etcdtriggertest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/engine"
+	"github.com/rezeropoint/etcdtrigger/v2/etcdtriggertest"
+	"github.com/rezeropoint/etcdtrigger/v2/store"
+)
+
+type databaseConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func TestEngineEndToEnd(t *testing.T) {
+	eng, srv := etcdtriggertest.NewEngine(t, &engine.Config{
+		ServiceName: "test",
+		Configs:     []core.WatchConfig{store.Bind[databaseConfig]("/app/config/database/")},
+	})
+
+	sub := etcdtriggertest.Watch(t, eng, "/app/config/")
+
+	srv.Put(t, "/app/config/database/main", `{"host":"localhost","port":5432}`)
+	event := etcdtriggertest.ExpectEvent(t, sub, "/app/config/database/main", core.EventTypePut, 5*time.Second)
+	if !event.IsCreate() {
+		t.Fatalf("Version = %d, want 1", event.Version)
+	}
+
+	var db databaseConfig
+	etcdtriggertest.EventuallyCached(t, eng, "/app/config/database/main", &db)
+	if db.Host != "localhost" {
+		t.Fatalf("Host = %q, want localhost", db.Host)
+	}
+
+	// 通过引擎写入，缓存与订阅都能观察到
+	want := databaseConfig{Host: "db.internal", Port: 5433}
+	if err := store.Put(context.Background(), eng, "/app/config/database/main", want); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	etcdtriggertest.ExpectEvent(t, sub, "/app/config/database/main", core.EventTypePut, 5*time.Second)
+	etcdtriggertest.EventuallyEqual(t, eng, "/app/config/database/main", want)
+
+	srv.Delete(t, "/app/config/database/main")
+	etcdtriggertest.ExpectEvent(t, sub, "/app/config/database/main", core.EventTypeDelete, 5*time.Second)
+	etcdtriggertest.Eventually(t, etcdtriggertest.DefaultTimeout, func() bool {
+		_, ok := store.Get[databaseConfig](eng, "/app/config/database/main")
+		return !ok
+	}, "删除后缓存中仍存在配置")
+}
+
+func TestStartEtcdIsolated(t *testing.T) {
+	first := etcdtriggertest.StartEtcd(t)
+	second := etcdtriggertest.StartEtcd(t)
+	if first.Endpoint == second.Endpoint {
+		t.Fatalf("两个实例使用了相同的地址 %s", first.Endpoint)
+	}
+
+	first.Put(t, "/k", "v")
+	resp, err := second.Client.Get(context.Background(), "/k")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(resp.Kvs) != 0 {
+		t.Fatal("实例之间共享了数据")
+	}
+}
@@ -0,0 +1,198 @@
+package etcdtriggertest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/engine"
+)
+
+// Subscription 记录事件的订阅
+type Subscription struct {
+	core.Subscription
+	events chan *core.WatchEvent
+}
+
+// Watch 订阅前缀并记录收到的事件
+// 参数：
+//   - t: 测试上下文，订阅失败时终止测试
+//   - eng: 配置管理引擎
+//   - prefix: 监听的键或前缀
+//   - opts: 订阅选项
+//
+// 返回：
+//   - *Subscription: 用于 ExpectEvent 的订阅，测试结束时自动取消
+//
+// 说明：
+//   - 事件进入无界队列，回调不会因为测试未及时读取而阻塞
+func Watch(t testing.TB, eng engine.Engine, prefix string, opts ...core.WatchOption) *Subscription {
+	t.Helper()
+
+	stop := make(chan struct{})
+	queue := newEventQueue(stop)
+	sub, err := eng.Watch(prefix, func(event *core.WatchEvent) error {
+		queue.push(event)
+		return nil
+	}, opts...)
+	if err != nil {
+		close(stop)
+		t.Fatalf("订阅 %s 失败: %v", prefix, err)
+	}
+	t.Cleanup(sub.Unsubscribe)
+
+	// 订阅结束后不再有回调，停止队列协程
+	go func() {
+		<-sub.Done()
+		close(stop)
+	}()
+
+	return &Subscription{
+		Subscription: sub,
+		events:       queue.out,
+	}
+}
+
+// ExpectEvent 等待指定键与类型的事件
+// 参数：
+//   - t: 测试上下文
+//   - sub: Watch 返回的订阅
+//   - key: 期望的键
+//   - eventType: 期望的事件类型
+//   - timeout: 最长等待时间
+//
+// 返回：
+//   - *core.WatchEvent: 匹配的事件
+//
+// 说明：
+//   - 按顺序消费事件，匹配前的其他事件被丢弃
+//   - 超时或订阅结束仍未匹配时终止测试
+func ExpectEvent(t testing.TB, sub *Subscription, key string, eventType core.EventType, timeout time.Duration) *core.WatchEvent {
+	t.Helper()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case event := <-sub.events:
+			if event.Key == key && event.EventType == eventType {
+				return event
+			}
+		case <-sub.Done():
+			t.Fatalf("等待 %s %s 时订阅已结束: %v", eventType, key, sub.Err())
+			return nil
+		case <-timer.C:
+			t.Fatalf("%v 内未收到 %s %s", timeout, eventType, key)
+			return nil
+		}
+	}
+}
+
+// EventuallyCached 等待配置进入缓存
+// 参数：
+//   - t: 测试上下文
+//   - eng: 配置管理引擎
+//   - key: 配置键
+//   - result: 指向结构体的指针，用于接收配置
+//
+// 说明：
+//   - 在 DefaultTimeout 内轮询 GetConfig，超时终止测试
+//   - 只等待键存在；需要等待特定值时使用 EventuallyCachedFunc
+func EventuallyCached(t testing.TB, eng engine.Engine, key string, result any) {
+	t.Helper()
+
+	EventuallyCachedFunc(t, eng, key, result, func() bool {
+		return true
+	})
+}
+
+// EventuallyCachedFunc 等待缓存中的配置满足条件
+// 参数：
+//   - t: 测试上下文
+//   - eng: 配置管理引擎
+//   - key: 配置键
+//   - result: 指向结构体的指针，每次轮询时写入当前缓存的配置
+//   - cond: 判断 result 是否满足期望
+func EventuallyCachedFunc(t testing.TB, eng engine.Engine, key string, result any, cond func() bool) {
+	t.Helper()
+
+	Eventually(t, DefaultTimeout, func() bool {
+		return eng.GetConfig(key, result) && cond()
+	}, "%s 未进入缓存或不满足条件", key)
+}
+
+// EventuallyEqual 等待缓存中的配置等于期望值
+// 参数：
+//   - want: 期望的配置（结构体值，与 Store 绑定的类型相同）
+func EventuallyEqual[T any](t testing.TB, eng engine.Engine, key string, want T) {
+	t.Helper()
+
+	var got T
+	Eventually(t, DefaultTimeout, func() bool {
+		return eng.GetConfig(key, &got) && reflect.DeepEqual(got, want)
+	}, "%s 的缓存值为 %+v，期望 %+v", key, &got, want)
+}
+
+// Eventually 等待条件成立
+// 参数：
+//   - timeout: 最长等待时间
+//   - cond: 条件，每 10ms 检查一次
+//   - format, args: 超时时的失败信息
+func Eventually(t testing.TB, timeout time.Duration, cond func() bool, format string, args ...any) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// eventQueue 无界事件队列
+type eventQueue struct {
+	in  chan *core.WatchEvent
+	out chan *core.WatchEvent
+}
+
+// newEventQueue 创建无界事件队列，stop 关闭后停止
+func newEventQueue(stop <-chan struct{}) *eventQueue {
+	q := &eventQueue{
+		in:  make(chan *core.WatchEvent),
+		out: make(chan *core.WatchEvent),
+	}
+	go q.run(stop)
+	return q
+}
+
+// push 放入事件
+func (q *eventQueue) push(event *core.WatchEvent) {
+	q.in <- event
+}
+
+// run 在输入与输出之间缓冲事件
+func (q *eventQueue) run(stop <-chan struct{}) {
+	var pending []*core.WatchEvent
+	for {
+		var (
+			out  chan *core.WatchEvent
+			next *core.WatchEvent
+		)
+		if len(pending) > 0 {
+			out, next = q.out, pending[0]
+		}
+
+		select {
+		case event := <-q.in:
+			pending = append(pending, event)
+		case out <- next:
+			pending = pending[1:]
+		case <-stop:
+			return
+		}
+	}
+}
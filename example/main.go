@@ -43,7 +43,9 @@ func main() {
 	defer etcdClient.Close()
 
 	// 2. 创建引擎（传入 etcd 客户端）
-	eng := engine.NewEngine(etcdClient, &engine.Config{
+	initCtx, initCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer initCancel()
+	eng, err := engine.New(initCtx, etcdClient, &engine.Config{
 		PodName:     "example-pod",
 		ServiceName: "example-service",
 		// 预加载配置：自动监听并缓存到内存（Store 功能）
@@ -52,11 +54,19 @@ func main() {
 			{Path: "/app/config/redis/", Struct: &RedisConfig{}},
 		},
 	})
+	if err != nil {
+		log.Fatal("创建引擎失败:", err)
+	}
+
+	// 等待预加载配置完成初始加载
+	if err := eng.WaitReady(initCtx); err != nil {
+		log.Fatal("配置未就绪:", err)
+	}
 
 	// ---- Watcher 功能演示（原始操作）----
 
 	// 使用 Watch 原始回调模式
-	err = eng.Watch("/app/events/", func(event *core.WatchEvent) error {
+	eventSub, err := eng.Watch("/app/events/", func(event *core.WatchEvent) error {
 		if event.EventType.IsDelete() {
 			log.Printf("[Watcher] 键被删除: %s", event.Key)
 		} else {
@@ -67,13 +77,15 @@ func main() {
 	if err != nil {
 		log.Fatal("订阅失败:", err)
 	}
+	defer eventSub.Unsubscribe()
 
 	// ---- Store 功能演示（强类型缓存）----
 
 	// 使用 AddPrefixWatcher 前缀监听器
-	eng.AddPrefixWatcher("/app/config/", func(key string, eventType core.EventType) {
+	configSub := eng.AddPrefixWatcher("/app/config/", func(key string, eventType core.EventType) {
 		log.Printf("[Store] %s: %s", eventType, key)
 	})
+	defer configSub.Unsubscribe()
 
 	log.Println("开始监听配置变更...")
 
@@ -133,4 +145,11 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 	log.Println("收到退出信号，正在关闭...")
+
+	// 关闭引擎：取消所有监听并等待回调结束
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := eng.Close(ctx); err != nil {
+		log.Printf("关闭引擎失败: %v", err)
+	}
 }
@@ -0,0 +1,72 @@
+package dispatch
+
+import (
+	"context"
+	"hash/fnv"
+	"sync/atomic"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// Pool 按键分片的一组队列
+// 说明：
+//   - 同一键的事件总是进入同一队列，由同一回调协程按顺序处理
+//   - 不同键分散到不同队列，由各自的回调协程并发处理
+//   - 每个队列的容量与策略相同，深度指标为所有队列之和
+type Pool struct {
+	queues []*Queue
+}
+
+// NewPool 创建队列组
+// 参数：
+//   - config: 每个队列的配置
+//   - n: 队列数，不大于 0 时为 1
+func NewPool(config *Config, n int) *Pool {
+	n = max(n, 1)
+	depth := &atomic.Int64{}
+	queues := make([]*Queue, n)
+	for i := range queues {
+		queues[i] = newQueue(config, depth)
+	}
+	return &Pool{queues: queues}
+}
+
+// Len 返回队列数
+func (p *Pool) Len() int {
+	return len(p.queues)
+}
+
+// Queue 返回第 i 个队列
+func (p *Pool) Queue(i int) *Queue {
+	return p.queues[i]
+}
+
+// Push 投递事件到键所在的队列
+// 返回：
+//   - bool: 等待空位期间 ctx 取消时为 false，事件未入队
+func (p *Pool) Push(ctx context.Context, key string, value any) bool {
+	return p.queues[p.shard(key)].Push(ctx, key, value)
+}
+
+// Stats 返回所有队列的统计之和
+func (p *Pool) Stats() core.QueueStats {
+	var stats core.QueueStats
+	for _, queue := range p.queues {
+		current := queue.Stats()
+		stats.Capacity += current.Capacity
+		stats.Depth += current.Depth
+		stats.Dropped += current.Dropped
+		stats.Coalesced += current.Coalesced
+	}
+	return stats
+}
+
+// shard 返回键所在的队列序号
+func (p *Pool) shard(key string) int {
+	if len(p.queues) == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
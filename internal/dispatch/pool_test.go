@@ -0,0 +1,72 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPoolPerKeyOrdering(t *testing.T) {
+	const (
+		workers = 4
+		keys    = 16
+		events  = 50
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pool := NewPool(&Config{Size: 8}, workers)
+	if pool.Len() != workers {
+		t.Fatalf("Len = %d, want %d", pool.Len(), workers)
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]int)
+		wg   sync.WaitGroup
+	)
+	wg.Add(keys * events)
+	for i := range pool.Len() {
+		queue := pool.Queue(i)
+		go func() {
+			for {
+				value, ok := queue.Pop(ctx)
+				if !ok {
+					return
+				}
+				item := value.([2]any)
+				mu.Lock()
+				seen[item[0].(string)] = append(seen[item[0].(string)], item[1].(int))
+				mu.Unlock()
+				wg.Done()
+			}
+		}()
+	}
+
+	for seq := range events {
+		for k := range keys {
+			key := fmt.Sprintf("/key/%d", k)
+			if !pool.Push(ctx, key, [2]any{key, seq}) {
+				t.Fatalf("Push %s 失败", key)
+			}
+		}
+	}
+	wg.Wait()
+
+	for key, got := range seen {
+		for i, seq := range got {
+			if seq != i {
+				t.Fatalf("%s 的第 %d 个事件为 %d，同一键未按顺序处理", key, i, seq)
+			}
+		}
+	}
+	if len(seen) != keys {
+		t.Fatalf("处理了 %d 个键, want %d", len(seen), keys)
+	}
+
+	if stats := pool.Stats(); stats.Capacity != 8*workers {
+		t.Fatalf("Capacity = %d, want %d", stats.Capacity, 8*workers)
+	}
+}
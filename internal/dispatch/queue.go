@@ -0,0 +1,201 @@
+// Package dispatch 提供订阅的有界事件队列。
+//
+// 每个启用队列的订阅拥有独立的队列与回调协程，慢回调只会填满自己的队列，
+// 不会阻塞监听协程或其他订阅。队列满时按 core.OverflowPolicy 处理：
+//   - OverflowBlock: 投递方等待空位
+//   - OverflowDropOldest: 丢弃最早排队的事件
+//   - OverflowCoalesce: 同一键的事件在队列中原位合并，不同键占满队列时等待空位
+//
+// Pool 将事件按键分片到多个队列，每个队列一个回调协程，同一键保持顺序，不同键并发处理。
+package dispatch
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/metrics"
+)
+
+// Config 队列配置
+type Config struct {
+	Size         int                           // 队列容量
+	Policy       core.OverflowPolicy           // 队列满时的处理策略，为空时为 OverflowBlock
+	Prefix       string                        // 订阅的键或前缀，用作指标标签
+	Subscription string                        // 订阅 ID，用作指标标签
+	Metrics      *metrics.Metrics              // 指标记录器（可为 nil）
+	Merge        func(pending, latest any) any // 合并同一键的事件（可为 nil，为 nil 时保留 latest）
+	OnDiscard    func(value any)               // 事件被丢弃或合并时回调（可为 nil），在队列锁外执行
+}
+
+// Queue 有界事件队列
+// 说明：
+//   - 并发安全，支持多个投递方与一个或多个消费方
+type Queue struct {
+	config    *Config
+	mu        sync.Mutex
+	items     *list.List               // 排队的事件，元素为 *entry
+	keys      map[string]*list.Element // 键 -> 排队的事件，仅 OverflowCoalesce 维护
+	changed   chan struct{}            // 队列变化时关闭并替换，用于唤醒等待方
+	depth     *atomic.Int64            // 排队的事件数，同一 Pool 的队列共享，用于深度指标
+	dropped   uint64
+	coalesced uint64
+}
+
+// entry 排队的事件
+type entry struct {
+	key   string
+	value any
+}
+
+// NewQueue 创建队列
+func NewQueue(config *Config) *Queue {
+	return newQueue(config, &atomic.Int64{})
+}
+
+// newQueue 创建以 depth 计数的队列
+func newQueue(config *Config, depth *atomic.Int64) *Queue {
+	if config.Policy == "" {
+		config.Policy = core.OverflowBlock
+	}
+	return &Queue{
+		config:  config,
+		items:   list.New(),
+		keys:    make(map[string]*list.Element),
+		changed: make(chan struct{}),
+		depth:   depth,
+	}
+}
+
+// Push 投递事件
+// 参数：
+//   - ctx: 等待空位时的上下文
+//   - key: 事件键，OverflowCoalesce 据此合并
+//   - value: 事件
+//
+// 返回：
+//   - bool: 等待空位期间 ctx 取消时为 false，事件未入队
+func (q *Queue) Push(ctx context.Context, key string, value any) bool {
+	q.mu.Lock()
+	var discarded any
+	for {
+		if q.config.Policy == core.OverflowCoalesce {
+			if elem, ok := q.keys[key]; ok {
+				current := elem.Value.(*entry)
+				discarded = current.value
+				current.value = q.merge(current.value, value)
+				q.coalesced++
+				q.mu.Unlock()
+
+				q.config.Metrics.QueueDiscard(q.config.Prefix, q.config.Subscription, "coalesced")
+				q.discard(discarded)
+				return true
+			}
+		}
+
+		if q.items.Len() < q.config.Size {
+			break
+		}
+
+		if q.config.Policy == core.OverflowDropOldest {
+			front := q.items.Front()
+			q.items.Remove(front)
+			discarded = front.Value.(*entry).value
+			q.dropped++
+			break
+		}
+
+		changed := q.changed
+		q.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+		q.mu.Lock()
+	}
+
+	elem := q.items.PushBack(&entry{key: key, value: value})
+	if q.config.Policy == core.OverflowCoalesce {
+		q.keys[key] = elem
+	}
+	if discarded == nil {
+		q.depth.Add(1)
+	}
+	q.notify()
+	q.mu.Unlock()
+
+	q.config.Metrics.QueueDepth(q.config.Prefix, q.config.Subscription, int(q.depth.Load()))
+	if discarded != nil {
+		q.config.Metrics.QueueDiscard(q.config.Prefix, q.config.Subscription, "dropped")
+		q.discard(discarded)
+	}
+	return true
+}
+
+// Pop 取出最早排队的事件
+// 返回：
+//   - any: 事件
+//   - bool: 队列为空且 ctx 取消时为 false
+func (q *Queue) Pop(ctx context.Context) (any, bool) {
+	q.mu.Lock()
+	for q.items.Len() == 0 {
+		changed := q.changed
+		q.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, false
+		}
+		q.mu.Lock()
+	}
+
+	front := q.items.Front()
+	q.items.Remove(front)
+	current := front.Value.(*entry)
+	if q.keys[current.key] == front {
+		delete(q.keys, current.key)
+	}
+	q.depth.Add(-1)
+	q.notify()
+	q.mu.Unlock()
+
+	q.config.Metrics.QueueDepth(q.config.Prefix, q.config.Subscription, int(q.depth.Load()))
+	return current.value, true
+}
+
+// Stats 返回队列统计
+func (q *Queue) Stats() core.QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return core.QueueStats{
+		Capacity:  q.config.Size,
+		Depth:     q.items.Len(),
+		Dropped:   q.dropped,
+		Coalesced: q.coalesced,
+	}
+}
+
+// merge 合并同一键的事件
+func (q *Queue) merge(pending, latest any) any {
+	if q.config.Merge == nil {
+		return latest
+	}
+	return q.config.Merge(pending, latest)
+}
+
+// discard 通知事件被丢弃或合并
+func (q *Queue) discard(value any) {
+	if q.config.OnDiscard != nil {
+		q.config.OnDiscard(value)
+	}
+}
+
+// notify 唤醒所有等待方，需持有锁
+func (q *Queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
@@ -0,0 +1,159 @@
+package dispatch
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// push 依次投递 "键=值" 形式的事件
+func push(t *testing.T, q *Queue, items ...string) {
+	t.Helper()
+
+	for _, item := range items {
+		key, _, _ := strings.Cut(item, "=")
+		if !q.Push(context.Background(), key, item) {
+			t.Fatalf("Push %s 失败", item)
+		}
+	}
+}
+
+// drain 取出队列中的所有事件
+func drain(q *Queue) []any {
+	var values []any
+	for q.Stats().Depth > 0 {
+		value, _ := q.Pop(context.Background())
+		values = append(values, value)
+	}
+	return values
+}
+
+func TestQueueOverflow(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        core.OverflowPolicy
+		merge         func(pending, latest any) any
+		push          []string
+		want          []any
+		wantDiscarded []any
+		wantStats     core.QueueStats
+	}{
+		{
+			name:      "block within capacity",
+			policy:    core.OverflowBlock,
+			push:      []string{"a=1", "a=2"},
+			want:      []any{"a=1", "a=2"},
+			wantStats: core.QueueStats{Capacity: 2},
+		},
+		{
+			name:          "drop oldest",
+			policy:        core.OverflowDropOldest,
+			push:          []string{"a=1", "b=1", "c=1", "d=1"},
+			want:          []any{"c=1", "d=1"},
+			wantDiscarded: []any{"a=1", "b=1"},
+			wantStats:     core.QueueStats{Capacity: 2, Dropped: 2},
+		},
+		{
+			name:          "coalesce keeps latest",
+			policy:        core.OverflowCoalesce,
+			push:          []string{"a=1", "b=1", "a=2", "a=3"},
+			want:          []any{"a=3", "b=1"},
+			wantDiscarded: []any{"a=1", "a=2"},
+			wantStats:     core.QueueStats{Capacity: 2, Coalesced: 2},
+		},
+		{
+			name:   "coalesce merges",
+			policy: core.OverflowCoalesce,
+			merge: func(pending, latest any) any {
+				return pending.(string) + "+" + latest.(string)
+			},
+			push:          []string{"a=1", "a=2"},
+			want:          []any{"a=1+a=2"},
+			wantDiscarded: []any{"a=1"},
+			wantStats:     core.QueueStats{Capacity: 2, Coalesced: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var discarded []any
+			q := NewQueue(&Config{
+				Size:   2,
+				Policy: tt.policy,
+				Merge:  tt.merge,
+				OnDiscard: func(value any) {
+					discarded = append(discarded, value)
+				},
+			})
+
+			push(t, q, tt.push...)
+
+			stats := q.Stats()
+			stats.Depth = 0
+			if stats != tt.wantStats {
+				t.Fatalf("Stats = %+v, want %+v", stats, tt.wantStats)
+			}
+			if got := drain(q); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("出队 = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(discarded, tt.wantDiscarded) {
+				t.Fatalf("丢弃 = %v, want %v", discarded, tt.wantDiscarded)
+			}
+		})
+	}
+}
+
+func TestQueueBlocksWhenFull(t *testing.T) {
+	for _, policy := range []core.OverflowPolicy{"", core.OverflowBlock, core.OverflowCoalesce} {
+		t.Run(string(policy), func(t *testing.T) {
+			q := NewQueue(&Config{Size: 1, Policy: policy})
+			push(t, q, "a=1")
+
+			pushed := make(chan bool, 1)
+			go func() {
+				pushed <- q.Push(context.Background(), "b", "b=1")
+			}()
+
+			select {
+			case <-pushed:
+				t.Fatal("队列已满时投递未阻塞")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			if value, _ := q.Pop(context.Background()); value != "a=1" {
+				t.Fatalf("Pop = %v, want a=1", value)
+			}
+			select {
+			case ok := <-pushed:
+				if !ok {
+					t.Fatal("有空位后投递失败")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("有空位后投递仍阻塞")
+			}
+		})
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	q := NewQueue(&Config{Size: 1})
+	push(t, q, "a=1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if q.Push(ctx, "b", "b=1") {
+		t.Fatal("ctx 取消后投递成功")
+	}
+	if depth := q.Stats().Depth; depth != 1 {
+		t.Fatalf("Depth = %d, want 1", depth)
+	}
+
+	_, _ = q.Pop(context.Background())
+	if _, ok := q.Pop(ctx); ok {
+		t.Fatal("队列为空且 ctx 取消时 Pop 成功")
+	}
+}
@@ -0,0 +1,101 @@
+// Package lifecycle 管理后台协程与回调的生命周期。
+//
+// Group 为各管理器提供统一的关闭语义：
+//   - 所有监听协程共享同一个根上下文，关闭时统一取消
+//   - 正在执行的回调通过 Acquire/release 计数，关闭时等待其完成
+//   - 关闭后拒绝新的协程与回调
+package lifecycle
+
+import (
+	"context"
+	"sync"
+)
+
+// Group 协程组
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewGroup 创建协程组
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Context 返回协程组的根上下文，关闭时被取消
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Closed 是否已关闭
+func (g *Group) Closed() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.closed
+}
+
+// Acquire 登记一个执行单元
+// 返回：
+//   - release: 执行完成后必须调用
+//   - ok: 协程组已关闭时为 false
+func (g *Group) Acquire() (release func(), ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil, false
+	}
+
+	g.wg.Add(1)
+	return g.wg.Done, true
+}
+
+// Go 在协程组内启动协程
+// 返回：
+//   - bool: 协程组已关闭时为 false，协程不会启动
+func (g *Group) Go(fn func(ctx context.Context)) bool {
+	release, ok := g.Acquire()
+	if !ok {
+		return false
+	}
+
+	go func() {
+		defer release()
+		fn(g.ctx)
+	}()
+
+	return true
+}
+
+// Close 关闭协程组
+// 说明：
+//   - 取消根上下文并等待所有执行单元结束
+//   - ctx 到期时立即返回 ctx.Err()，剩余协程会在各自退出后结束
+//   - 可重复调用
+func (g *Group) Close(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,79 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGroupCloseWaits(t *testing.T) {
+	g := NewGroup()
+
+	stopped := make(chan struct{})
+	if !g.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		close(stopped)
+	}) {
+		t.Fatal("Go 在关闭前返回 false")
+	}
+
+	release, ok := g.Acquire()
+	if !ok {
+		t.Fatal("Acquire 在关闭前返回 false")
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+
+	if err := g.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("Close 在协程结束前返回")
+	}
+	if g.Context().Err() == nil {
+		t.Fatal("Close 后根上下文未取消")
+	}
+}
+
+func TestGroupCloseTimeout(t *testing.T) {
+	g := NewGroup()
+
+	release, _ := g.Acquire()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := g.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestGroupRejectsAfterClose(t *testing.T) {
+	g := NewGroup()
+	if g.Closed() {
+		t.Fatal("新建的协程组已关闭")
+	}
+
+	if err := g.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := g.Close(context.Background()); err != nil {
+		t.Fatalf("重复 Close: %v", err)
+	}
+
+	if !g.Closed() {
+		t.Fatal("Close 后 Closed 为 false")
+	}
+	if _, ok := g.Acquire(); ok {
+		t.Fatal("Close 后 Acquire 成功")
+	}
+	if g.Go(func(context.Context) { t.Error("Close 后协程被启动") }) {
+		t.Fatal("Close 后 Go 返回 true")
+	}
+}
@@ -0,0 +1,310 @@
+// Package metrics 提供引擎的 Prometheus 指标。
+//
+// 指标基于 go-zero core/metric，注册到 Prometheus 默认注册表，
+// 仅在 go-zero 的 Prometheus 上报开启时（DevServer 或 prometheus.StartAgent）记录。
+// 指标向量在首次调用 New 时注册，进程内所有引擎共享。
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/zeromicro/go-zero/core/metric"
+)
+
+const namespace = "etcdtrigger"
+
+// 写操作名称，用作 op 标签
+const (
+	OpPutConfig    = "put_config"
+	OpDeleteConfig = "delete_config"
+	OpWatchPut     = "watch_put"
+	OpWatchDelete  = "watch_delete"
+)
+
+var (
+	registerOnce sync.Once
+
+	eventsTotal       metric.CounterVec
+	callbackDuration  metric.HistogramVec
+	callbackErrors    metric.CounterVec
+	callbackPanics    metric.CounterVec
+	callbackRetries   metric.CounterVec
+	deadLetters       metric.CounterVec
+	queueDepth        metric.GaugeVec
+	queueDiscarded    metric.CounterVec
+	watchReconnects   metric.CounterVec
+	watchCompactions  metric.CounterVec
+	revisionLag       metric.GaugeVec
+	cacheEntries      metric.GaugeVec
+	unmarshalFailures metric.CounterVec
+	writeDuration     metric.HistogramVec
+	writeErrors       metric.CounterVec
+)
+
+// Metrics 指标记录器
+// 说明：
+//   - nil 表示未启用，所有方法均可在 nil 上调用
+type Metrics struct{}
+
+// New 创建指标记录器，首次调用时注册指标
+func New() *Metrics {
+	registerOnce.Do(register)
+	return &Metrics{}
+}
+
+// Event 记录收到的事件
+// 参数：
+//   - prefix: 监听前缀
+//   - eventType: 事件类型
+func (m *Metrics) Event(prefix string, eventType core.EventType) {
+	if m == nil {
+		return
+	}
+	eventsTotal.Inc(prefix, eventType.String())
+}
+
+// Callback 记录回调耗时与错误
+// 参数：
+//   - prefix: 订阅的键或前缀
+//   - subscription: 订阅 ID，区分同一前缀上的多个订阅
+//   - duration: 回调耗时
+//   - err: 回调返回的错误
+func (m *Metrics) Callback(prefix, subscription string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	callbackDuration.Observe(duration.Milliseconds(), prefix, subscription)
+	if err != nil {
+		callbackErrors.Inc(prefix, subscription)
+	}
+}
+
+// Panic 记录回调 panic
+// 参数：
+//   - prefix: 订阅的键或前缀
+//   - subscription: 订阅 ID，不属于订阅的回调（如 Validate）为空
+func (m *Metrics) Panic(prefix, subscription string) {
+	if m == nil {
+		return
+	}
+	callbackPanics.Inc(prefix, subscription)
+}
+
+// Retry 记录回调失败后的重试
+// 参数：
+//   - prefix: 订阅的键或前缀
+//   - subscription: 订阅 ID
+func (m *Metrics) Retry(prefix, subscription string) {
+	if m == nil {
+		return
+	}
+	callbackRetries.Inc(prefix, subscription)
+}
+
+// DeadLetter 记录写入死信的事件
+// 参数：
+//   - prefix: 订阅的键或前缀
+//   - subscription: 订阅 ID
+func (m *Metrics) DeadLetter(prefix, subscription string) {
+	if m == nil {
+		return
+	}
+	deadLetters.Inc(prefix, subscription)
+}
+
+// QueueDepth 记录订阅队列深度
+// 参数：
+//   - prefix: 订阅的键或前缀
+//   - subscription: 订阅 ID
+//   - depth: 当前排队的事件数
+func (m *Metrics) QueueDepth(prefix, subscription string, depth int) {
+	if m == nil {
+		return
+	}
+	queueDepth.Set(float64(depth), prefix, subscription)
+}
+
+// QueueDiscard 记录订阅队列跳过的事件
+// 参数：
+//   - prefix: 订阅的键或前缀
+//   - subscription: 订阅 ID
+//   - reason: dropped 或 coalesced
+func (m *Metrics) QueueDiscard(prefix, subscription, reason string) {
+	if m == nil {
+		return
+	}
+	queueDiscarded.Inc(prefix, subscription, reason)
+}
+
+// Reconnect 记录监听重连
+func (m *Metrics) Reconnect(prefix string) {
+	if m == nil {
+		return
+	}
+	watchReconnects.Inc(prefix)
+}
+
+// Compaction 记录监听版本被压缩后的重新同步
+func (m *Metrics) Compaction(prefix string) {
+	if m == nil {
+		return
+	}
+	watchCompactions.Inc(prefix)
+}
+
+// RevisionLag 记录版本落后量
+// 参数：
+//   - lag: 监听响应时的存储版本与最后处理的事件版本之差
+func (m *Metrics) RevisionLag(prefix string, lag int64) {
+	if m == nil {
+		return
+	}
+	revisionLag.Set(float64(lag), prefix)
+}
+
+// CacheEntries 记录路径缓存的条目数
+// 参数：
+//   - path: WatchConfig.Path
+//   - count: 当前条目数
+func (m *Metrics) CacheEntries(path string, count int64) {
+	if m == nil {
+		return
+	}
+	cacheEntries.Set(float64(count), path)
+}
+
+// UnmarshalFailure 记录反序列化失败
+func (m *Metrics) UnmarshalFailure(path string) {
+	if m == nil {
+		return
+	}
+	unmarshalFailures.Inc(path)
+}
+
+// Write 记录写操作耗时与错误
+// 参数：
+//   - op: 写操作名称，取值为 Op 常量
+//   - duration: 写操作耗时
+//   - err: 写操作返回的错误
+func (m *Metrics) Write(op string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	writeDuration.Observe(duration.Milliseconds(), op)
+	if err != nil {
+		writeErrors.Inc(op)
+	}
+}
+
+// register 注册所有指标
+func register() {
+	eventsTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "watch",
+		Name:      "events_total",
+		Help:      "etcdtrigger watch events received, by prefix and type.",
+		Labels:    []string{"prefix", "type"},
+	})
+	callbackDuration = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: "callback",
+		Name:      "duration_ms",
+		Help:      "etcdtrigger callback duration(ms), by subscription.",
+		Labels:    []string{"prefix", "subscription"},
+		Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+	})
+	callbackErrors = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "callback",
+		Name:      "errors_total",
+		Help:      "etcdtrigger callback errors, by subscription.",
+		Labels:    []string{"prefix", "subscription"},
+	})
+	callbackPanics = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "callback",
+		Name:      "panics_total",
+		Help:      "etcdtrigger callback panics recovered, by subscription.",
+		Labels:    []string{"prefix", "subscription"},
+	})
+	callbackRetries = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "callback",
+		Name:      "retries_total",
+		Help:      "etcdtrigger callback retries after failures, by subscription.",
+		Labels:    []string{"prefix", "subscription"},
+	})
+	deadLetters = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "callback",
+		Name:      "dead_letters_total",
+		Help:      "etcdtrigger events written to the dead letter prefix after retries were exhausted, by subscription.",
+		Labels:    []string{"prefix", "subscription"},
+	})
+	queueDepth = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "depth",
+		Help:      "etcdtrigger events waiting in subscription queues, by subscription.",
+		Labels:    []string{"prefix", "subscription"},
+	})
+	queueDiscarded = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "discarded_total",
+		Help:      "etcdtrigger events dropped or coalesced by subscription queues, by subscription and reason.",
+		Labels:    []string{"prefix", "subscription", "reason"},
+	})
+	watchReconnects = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "watch",
+		Name:      "reconnects_total",
+		Help:      "etcdtrigger watch reconnects, by prefix.",
+		Labels:    []string{"prefix"},
+	})
+	watchCompactions = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "watch",
+		Name:      "compactions_total",
+		Help:      "etcdtrigger watch resyncs caused by compaction, by prefix.",
+		Labels:    []string{"prefix"},
+	})
+	revisionLag = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: "watch",
+		Name:      "revision_lag",
+		Help:      "etcdtrigger revisions between the store and the last handled event, by prefix.",
+		Labels:    []string{"prefix"},
+	})
+	cacheEntries = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: "store",
+		Name:      "cache_entries",
+		Help:      "etcdtrigger cached config entries, by WatchConfig path.",
+		Labels:    []string{"path"},
+	})
+	unmarshalFailures = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "store",
+		Name:      "unmarshal_failures_total",
+		Help:      "etcdtrigger config unmarshal failures, by WatchConfig path.",
+		Labels:    []string{"path"},
+	})
+	writeDuration = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: "write",
+		Name:      "duration_ms",
+		Help:      "etcdtrigger write duration(ms), by operation.",
+		Labels:    []string{"op"},
+		Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+	})
+	writeErrors = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "write",
+		Name:      "errors_total",
+		Help:      "etcdtrigger write errors, by operation.",
+		Labels:    []string{"op"},
+	})
+}
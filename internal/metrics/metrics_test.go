@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	zeroprometheus "github.com/zeromicro/go-zero/core/prometheus"
+)
+
+// value 返回默认注册表中带指定标签的指标值，不存在时为 0
+func value(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if !hasLabels(m, labels) {
+				continue
+			}
+			switch {
+			case m.Counter != nil:
+				return m.Counter.GetValue()
+			case m.Gauge != nil:
+				return m.Gauge.GetValue()
+			case m.Histogram != nil:
+				return float64(m.Histogram.GetSampleCount())
+			}
+		}
+	}
+	return 0
+}
+
+func hasLabels(m *dto.Metric, labels map[string]string) bool {
+	matched := 0
+	for _, pair := range m.GetLabel() {
+		if want, ok := labels[pair.GetName()]; ok {
+			if pair.GetValue() != want {
+				return false
+			}
+			matched++
+		}
+	}
+	return matched == len(labels)
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.Event("/app/", core.EventTypePut)
+	m.Callback("/app/", "watch-1", time.Millisecond, errors.New("失败"))
+	m.Panic("/app/", "watch-1")
+	m.Retry("/app/", "watch-1")
+	m.DeadLetter("/app/", "watch-1")
+	m.QueueDepth("/app/", "watch-1", 1)
+	m.QueueDiscard("/app/", "watch-1", "dropped")
+	m.Reconnect("/app/")
+	m.Compaction("/app/")
+	m.RevisionLag("/app/", 1)
+	m.CacheEntries("/app/", 1)
+	m.UnmarshalFailure("/app/")
+	m.Write(OpPutConfig, time.Millisecond, nil)
+}
+
+func TestMetrics(t *testing.T) {
+	zeroprometheus.Enable()
+	m := New()
+	if New() == nil {
+		t.Fatal("重复调用 New 不应失败")
+	}
+
+	// 同一前缀上的两个订阅分别计数，计数器比较增量以便重复运行
+	first := map[string]string{"prefix": "/metrics/", "subscription": "watch-1"}
+	second := map[string]string{"prefix": "/metrics/", "subscription": "watch-2"}
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+		gauge  bool
+	}{
+		{name: "etcdtrigger_watch_events_total", labels: map[string]string{"prefix": "/metrics/", "type": "PUT"}, want: 1},
+		{name: "etcdtrigger_callback_duration_ms", labels: first, want: 2},
+		{name: "etcdtrigger_callback_errors_total", labels: first, want: 1},
+		{name: "etcdtrigger_callback_duration_ms", labels: second, want: 1},
+		{name: "etcdtrigger_callback_errors_total", labels: second, want: 0},
+		{name: "etcdtrigger_queue_depth", labels: first, want: 3, gauge: true},
+		{name: "etcdtrigger_queue_depth", labels: second, want: 1, gauge: true},
+		{name: "etcdtrigger_queue_discarded_total", labels: map[string]string{"prefix": "/metrics/", "subscription": "watch-1", "reason": "coalesced"}, want: 1},
+		{name: "etcdtrigger_store_cache_entries", labels: map[string]string{"path": "/metrics/"}, want: 5, gauge: true},
+		{name: "etcdtrigger_write_errors_total", labels: map[string]string{"op": OpDeleteConfig}, want: 1},
+	}
+	before := make([]float64, len(tests))
+	for i, tt := range tests {
+		if !tt.gauge {
+			before[i] = value(t, tt.name, tt.labels)
+		}
+	}
+
+	m.Event("/metrics/", core.EventTypePut)
+	m.Callback("/metrics/", "watch-1", time.Millisecond, nil)
+	m.Callback("/metrics/", "watch-1", time.Millisecond, errors.New("失败"))
+	m.Callback("/metrics/", "watch-2", time.Millisecond, nil)
+	m.QueueDepth("/metrics/", "watch-1", 3)
+	m.QueueDepth("/metrics/", "watch-2", 1)
+	m.QueueDiscard("/metrics/", "watch-1", "coalesced")
+	m.CacheEntries("/metrics/", 5)
+	m.Write(OpDeleteConfig, time.Millisecond, errors.New("失败"))
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := value(t, tt.name, tt.labels) - before[i]; got != tt.want {
+				t.Fatalf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Package recovery 提供回调的 panic 隔离。
+package recovery
+
+import (
+	"runtime/debug"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// Call 执行回调，回调 panic 时恢复并转换为错误
+// 参数：
+//   - key: 事件键，记录到 PanicError
+//   - fn: 回调
+//
+// 返回：
+//   - error: 回调返回的错误，panic 时为 *core.PanicError
+func Call(key string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &core.PanicError{
+				Key:   key,
+				Value: r,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+
+	return fn()
+}
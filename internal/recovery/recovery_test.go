@@ -0,0 +1,34 @@
+package recovery
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+func TestCall(t *testing.T) {
+	failure := errors.New("处理失败")
+	if err := Call("/app/db", func() error { return failure }); err != failure {
+		t.Fatalf("Call = %v, want 回调返回的错误", err)
+	}
+	if err := Call("/app/db", func() error { return nil }); err != nil {
+		t.Fatalf("Call = %v, want nil", err)
+	}
+}
+
+func TestCallPanic(t *testing.T) {
+	err := Call("/app/db", func() error { panic("boom") })
+
+	var panicErr *core.PanicError
+	if !errors.As(err, &panicErr) || !errors.Is(err, core.ErrCallbackPanic) {
+		t.Fatalf("Call = %v, want *core.PanicError", err)
+	}
+	if panicErr.Key != "/app/db" || panicErr.Value != "boom" {
+		t.Fatalf("PanicError = %s, %v", panicErr.Key, panicErr.Value)
+	}
+	if !strings.Contains(string(panicErr.Stack), "TestCallPanic") {
+		t.Fatalf("Stack 不包含 panic 的调用点:\n%s", panicErr.Stack)
+	}
+}
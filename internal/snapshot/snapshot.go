@@ -0,0 +1,139 @@
+// Package snapshot 提供前缀快照的本地持久化。
+//
+// 每个前缀一个文件，记录快照版本与每个键的原始值、版本元数据和校验和：
+//   - Save: 写入临时文件后原子重命名，进程崩溃不会留下不完整的快照
+//   - Load: 读取并逐条校验，任一条目校验和不匹配时整个快照视为损坏
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// Entry 快照条目
+type Entry struct {
+	Key            string `json:"key"`
+	Value          []byte `json:"value"`
+	CreateRevision int64  `json:"createRevision"`
+	ModRevision    int64  `json:"modRevision"`
+	Version        int64  `json:"version"`
+	Lease          int64  `json:"lease"`
+	Checksum       string `json:"checksum"` // Value 的 SHA-256
+}
+
+// Snapshot 前缀快照
+type Snapshot struct {
+	Prefix   string   `json:"prefix"`
+	Revision int64    `json:"revision"` // 快照对应的 etcd 版本
+	Entries  []*Entry `json:"entries"`
+}
+
+// Dir 快照目录
+type Dir struct {
+	path string
+}
+
+// NewDir 创建快照目录
+// 参数：
+//   - path: 目录路径，不存在时自动创建
+//
+// 返回：
+//   - *Dir: 快照目录
+//   - error: 目录创建失败时返回错误
+func NewDir(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("%w: %v", core.ErrSnapshotSaveFailed, err)
+	}
+
+	return &Dir{path: path}, nil
+}
+
+// Load 读取前缀快照
+// 返回：
+//   - *Snapshot: 快照，不存在时为 nil
+//   - error: 读取、解析失败或校验和不匹配时返回错误
+func (d *Dir) Load(prefix string) (*Snapshot, error) {
+	data, err := os.ReadFile(d.file(prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", core.ErrSnapshotLoadFailed, err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("%w: %v", core.ErrSnapshotLoadFailed, err)
+	}
+
+	if snap.Prefix != prefix {
+		return nil, fmt.Errorf("%w: 快照前缀 %s 与 %s 不一致", core.ErrSnapshotCorrupted, snap.Prefix, prefix)
+	}
+
+	for _, entry := range snap.Entries {
+		if entry.Checksum != checksum(entry.Value) {
+			return nil, fmt.Errorf("%w: %s", core.ErrSnapshotCorrupted, entry.Key)
+		}
+	}
+
+	return &snap, nil
+}
+
+// Save 保存前缀快照
+// 说明：
+//   - 自动计算每个条目的校验和
+func (d *Dir) Save(snap *Snapshot) error {
+	for _, entry := range snap.Entries {
+		entry.Checksum = checksum(entry.Value)
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("%w: %v", core.ErrSnapshotSaveFailed, err)
+	}
+
+	tmp, err := os.CreateTemp(d.path, ".snapshot-*")
+	if err != nil {
+		return fmt.Errorf("%w: %v", core.ErrSnapshotSaveFailed, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w: %v", core.ErrSnapshotSaveFailed, err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w: %v", core.ErrSnapshotSaveFailed, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%w: %v", core.ErrSnapshotSaveFailed, err)
+	}
+
+	if err := os.Rename(tmp.Name(), d.file(snap.Prefix)); err != nil {
+		return fmt.Errorf("%w: %v", core.ErrSnapshotSaveFailed, err)
+	}
+
+	return nil
+}
+
+// file 返回快照文件路径
+func (d *Dir) file(prefix string) string {
+	return filepath.Join(d.path, url.PathEscape(prefix)+".snapshot")
+}
+
+// checksum 计算值的校验和
+func checksum(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// newTestDir 在临时目录中创建快照目录
+func newTestDir(t *testing.T) *Dir {
+	t.Helper()
+
+	dir, err := NewDir(filepath.Join(t.TempDir(), "snapshots"))
+	if err != nil {
+		t.Fatalf("NewDir: %v", err)
+	}
+	return dir
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := newTestDir(t)
+
+	if snap, err := dir.Load("/app/"); snap != nil || err != nil {
+		t.Fatalf("不存在时 Load = %v, %v, want nil, nil", snap, err)
+	}
+
+	want := &Snapshot{
+		Prefix:   "/app/",
+		Revision: 12,
+		Entries: []*Entry{
+			{Key: "/app/a", Value: []byte(`{"name":"a"}`), CreateRevision: 3, ModRevision: 10, Version: 2, Lease: 7},
+			{Key: "/app/b", Value: []byte{}, CreateRevision: 12, ModRevision: 12, Version: 1},
+		},
+	}
+	if err := dir.Save(want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := dir.Load("/app/")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Load = %+v, want %+v", got, want)
+	}
+
+	// 不残留临时文件
+	entries, err := os.ReadDir(dir.path)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "%2Fapp%2F.snapshot" {
+		t.Fatalf("快照目录 = %v, %v", entries, err)
+	}
+}
+
+func TestLoadCorrupted(t *testing.T) {
+	dir := newTestDir(t)
+	if err := dir.Save(&Snapshot{Prefix: "/app/", Revision: 5, Entries: []*Entry{{Key: "/app/a", Value: []byte("1")}}}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// rewrite 修改快照文件后重新写入
+	rewrite := func(t *testing.T, prefix string, modify func(*Snapshot)) {
+		t.Helper()
+
+		data, err := os.ReadFile(dir.file("/app/"))
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		modify(&snap)
+		if data, err = json.Marshal(&snap); err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		if err := os.WriteFile(dir.file(prefix), data, 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		prefix  string
+		write   func(t *testing.T)
+		wantErr error
+	}{
+		{
+			name:    "checksum mismatch",
+			prefix:  "/app/",
+			write:   func(t *testing.T) { rewrite(t, "/app/", func(s *Snapshot) { s.Entries[0].Value = []byte("2") }) },
+			wantErr: core.ErrSnapshotCorrupted,
+		},
+		{
+			name:    "prefix mismatch",
+			prefix:  "/other/",
+			write:   func(t *testing.T) { rewrite(t, "/other/", func(*Snapshot) {}) },
+			wantErr: core.ErrSnapshotCorrupted,
+		},
+		{
+			name:   "malformed",
+			prefix: "/broken/",
+			write: func(t *testing.T) {
+				if err := os.WriteFile(dir.file("/broken/"), []byte(`{"prefix":`), 0o644); err != nil {
+					t.Fatalf("WriteFile: %v", err)
+				}
+			},
+			wantErr: core.ErrSnapshotLoadFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.write(t)
+			if snap, err := dir.Load(tt.prefix); snap != nil || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Load = %v, %v, want %v", snap, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewDirInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := NewDir(filepath.Join(file, "snapshots")); !errors.Is(err, core.ErrSnapshotSaveFailed) {
+		t.Fatalf("NewDir = %v, want ErrSnapshotSaveFailed", err)
+	}
+}
@@ -1,8 +1,18 @@
 package store
 
-import "github.com/rezeropoint/etcdtrigger/v2/core"
+import (
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/metrics"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/tracing"
+)
 
 // Config 配置存储管理器配置
 type Config struct {
-	Configs []core.WatchConfig // 预加载配置列表
+	Configs      []core.WatchConfig      // 预加载配置列表
+	FailFast     bool                    // 预加载配置初始加载失败时是否直接返回错误
+	OnWatchState core.WatchStateCallback // 监听状态回调（可为 nil）
+	SnapshotDir  string                  // 本地快照目录（可为空），为空时不持久化缓存
+	Metrics      *metrics.Metrics        // 指标记录器（可为 nil）
+	Tracer       *tracing.Tracer         // 链路追踪（可为 nil）
+	OnError      core.ErrorCallback      // 回调 panic 的错误回调（可为 nil）
 }
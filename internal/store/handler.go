@@ -4,63 +4,130 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/rezeropoint/etcdtrigger/v2/codec"
 	"github.com/rezeropoint/etcdtrigger/v2/core"
-	jsoniter "github.com/json-iterator/go"
-	"github.com/zeromicro/go-zero/core/logx"
-	clientv3 "go.etcd.io/etcd/client/v3"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/lifecycle"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/metrics"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/snapshot"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var jsonIter = jsoniter.ConfigCompatibleWithStandardLibrary
-
 // storeManager 配置存储管理器实现
 type storeManager struct {
-	client         *clientv3.Client
+	backend        core.Backend
 	logCtx         *core.LogContext
-	data           sync.Map // 存储不同类型的配置实例
-	typeCaches     sync.Map // 缓存结构体类型
-	prefixWatchers sync.Map // 前缀监听器
+	config         *Config
+	data           sync.Map         // 路径缓存（路径 -> *pathCache）
+	prefixWatchers sync.Map         // 前缀监听器（订阅 ID -> *prefixWatcher）
+	watcherSeq     atomic.Uint64    // 前缀监听器订阅 ID 序列
+	group          *lifecycle.Group // 监听协程与回调的生命周期
+	ready          chan struct{}    // 预加载配置全部完成初始加载后关闭
+	snapshots      *snapshot.Dir    // 本地快照目录，未启用时为 nil
 }
 
 // newManager 创建配置存储管理器实例
-func newManager(client *clientv3.Client, logCtx *core.LogContext, config *Config) *storeManager {
+func newManager(ctx context.Context, backend core.Backend, logCtx *core.LogContext, config *Config) (*storeManager, error) {
 	manager := &storeManager{
-		client: client,
-		logCtx: logCtx,
+		backend: backend,
+		logCtx:  logCtx,
+		config:  config,
+		group:   lifecycle.NewGroup(),
+		ready:   make(chan struct{}),
+	}
+
+	for _, cfg := range config.Configs {
+		if cfg.Struct == nil {
+			continue
+		}
+		if err := checkConfig(cfg, logCtx); err != nil {
+			return nil, fmt.Errorf("%w: path=%s", err, cfg.Path)
+		}
+	}
+
+	if config.SnapshotDir != "" {
+		snapshots, err := snapshot.NewDir(config.SnapshotDir)
+		if err != nil {
+			return nil, err
+		}
+		manager.snapshots = snapshots
 	}
 
 	// 初始化预配置的监听
+	caches := make([]*pathCache, 0, len(config.Configs))
 	for _, cfg := range config.Configs {
-		if cfg.Struct != nil {
-			manager.initTypeStore(cfg.Struct)
-			if err := manager.initConfig(context.Background(), cfg); err != nil {
-				manager.log("init_config").WithFields(logx.Field("path", cfg.Path), logx.Field("error", err.Error())).Error("初始化配置失败")
+		if cfg.Struct == nil {
+			continue
+		}
+
+		cache, err := manager.addConfig(ctx, cfg, config.FailFast)
+		if err != nil {
+			manager.log("init_config").WithFields(core.Field("path", cfg.Path), core.Field("error", err.Error())).Error("初始化配置失败")
+			if config.FailFast {
+				_ = manager.Close(context.Background())
+				return nil, err
 			}
-			go manager.watchConfigChanges(context.Background(), cfg)
+			continue
 		}
+		caches = append(caches, cache)
 	}
 
-	return manager
+	// 等待所有预加载配置完成初始加载
+	manager.group.Go(func(ctx context.Context) {
+		for _, cache := range caches {
+			select {
+			case <-cache.ready:
+			case <-ctx.Done():
+				return
+			}
+		}
+		close(manager.ready)
+		manager.log("ready").Info("预加载配置已就绪")
+	})
+
+	return manager, nil
 }
 
 // GetConfig 从缓存获取配置
 func (m *storeManager) GetConfig(key string, result any) bool {
 	t := reflect.TypeOf(result)
-	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
-		m.log("get_config").WithFields(logx.Field("key", key), logx.Field("error", "result 必须是指向结构体的指针")).Error("参数类型错误")
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		m.log("get_config").WithFields(core.Field("key", key), core.Field("error", "result 必须是指向结构体的指针")).Error("参数类型错误")
 		return false
 	}
 
-	instanceMap, ok := m.data.Load(t)
-	if !ok {
-		m.log("get_config").WithFields(logx.Field("key", key), logx.Field("error", fmt.Sprintf("未找到类型 %v", t))).Error("类型未找到")
+	var (
+		typeFound bool
+		value     any
+	)
+	m.rangeCaches(func(cache *pathCache) bool {
+		if cache.typ != t {
+			return true
+		}
+		typeFound = true
+
+		if !strings.HasPrefix(key, cache.config.Path) {
+			return true
+		}
+
+		instance, ok := cache.entries.Load(key)
+		if ok {
+			value = instance
+		}
+		return !ok
+	})
+
+	if !typeFound {
+		m.log("get_config").WithFields(core.Field("key", key), core.Field("error", fmt.Sprintf("未找到类型 %v", t))).Error("类型未找到")
 		return false
 	}
 
-	typedMap := instanceMap.(*sync.Map)
-	value, ok := typedMap.Load(key)
-	if !ok {
+	if value == nil {
 		return false
 	}
 
@@ -71,73 +138,188 @@ func (m *storeManager) GetConfig(key string, result any) bool {
 // GetAllKeys 获取指定前缀的所有键
 func (m *storeManager) GetAllKeys(prefix string) []string {
 	keys := make([]string, 0)
-
-	m.data.Range(func(_, value any) bool {
-		instanceMap := value.(*sync.Map)
-		instanceMap.Range(func(key, _ any) bool {
-			keyStr, ok := key.(string)
-			if ok && len(keyStr) >= len(prefix) && keyStr[:len(prefix)] == prefix {
-				keys = append(keys, keyStr)
-			}
-			return true
-		})
-		return true
+	m.rangeKeys(prefix, func(key string, _ any) {
+		keys = append(keys, key)
 	})
 
 	return keys
 }
 
 // PutConfig 写入配置
-func (m *storeManager) PutConfig(ctx context.Context, key string, config any) error {
-	value, err := jsonIter.Marshal(config)
+// 说明：
+//   - 使用与 key 最长匹配的预加载路径的编解码器，未匹配时使用 JSON
+//   - 写入前执行与缓存相同的校验，校验失败时返回 core.ErrValidationFailed
+//   - config 可以是结构体值或指针，统一转换为指针后校验；与匹配路径绑定的类型不一致时返回 core.ErrInvalidConfig
+//   - 写入分层配置的某一层时使用该配置的编解码器，但不执行校验（单层通常只包含部分字段）
+func (m *storeManager) PutConfig(ctx context.Context, key string, config any) (err error) {
+	if m.group.Closed() {
+		return core.ErrConnectionClosed
+	}
+
+	ctx, span := m.config.Tracer.Start(ctx, "etcdtrigger.put_config", trace.SpanKindProducer, key)
+	start := time.Now()
+	defer func() {
+		m.config.Metrics.Write(metrics.OpPutConfig, time.Since(start), err)
+		tracing.End(span, err)
+	}()
+
+	cache, overlay := m.matchCache(key)
+	if !overlay {
+		config, err = normalizeConfig(cache, config)
+		if err == nil {
+			err = m.validateInstance(cache, key, config)
+		}
+		if err != nil {
+			m.logCtx.WithContext(ctx, "store", "put_config").WithFields(core.Field("key", key), core.Field("error", err.Error())).Error("校验失败")
+			return err
+		}
+	}
+
+	c := codec.JSON
+	if cache != nil {
+		c = cache.codec
+	}
+	value, err := c.Marshal(config)
 	if err != nil {
-		m.log("put_config").WithFields(logx.Field("key", key), logx.Field("error", err.Error())).Error("序列化失败")
+		m.logCtx.WithContext(ctx, "store", "put_config").WithFields(core.Field("key", key), core.Field("codec", c.Name()), core.Field("error", err.Error())).Error("序列化失败")
 		return fmt.Errorf("%w: %v", core.ErrMarshalFailed, err)
 	}
 
-	_, err = m.client.Put(ctx, key, string(value))
+	_, err = m.config.Tracer.Put(ctx, m.backend, key, value)
 	if err != nil {
-		m.log("put_config").WithFields(logx.Field("key", key), logx.Field("error", err.Error())).Error("写入失败")
+		m.logCtx.WithContext(ctx, "store", "put_config").WithFields(core.Field("key", key), core.Field("error", err.Error())).Error("写入失败")
 		return fmt.Errorf("%w: %v", core.ErrPutFailed, err)
 	}
 
-	m.log("put_config").WithFields(logx.Field("key", key)).Info("写入成功")
+	m.logCtx.WithContext(ctx, "store", "put_config").WithFields(core.Field("key", key)).Info("写入成功")
 	return nil
 }
 
 // DeleteConfig 删除配置
-func (m *storeManager) DeleteConfig(ctx context.Context, key string) error {
-	_, err := m.client.Delete(ctx, key)
+func (m *storeManager) DeleteConfig(ctx context.Context, key string) (err error) {
+	if m.group.Closed() {
+		return core.ErrConnectionClosed
+	}
+
+	ctx, span := m.config.Tracer.Start(ctx, "etcdtrigger.delete_config", trace.SpanKindProducer, key)
+	start := time.Now()
+	defer func() {
+		m.config.Metrics.Write(metrics.OpDeleteConfig, time.Since(start), err)
+		tracing.End(span, err)
+	}()
+
+	_, err = m.config.Tracer.Delete(ctx, m.backend, key)
 	if err != nil {
-		m.log("delete_config").WithFields(logx.Field("key", key), logx.Field("error", err.Error())).Error("删除失败")
+		m.logCtx.WithContext(ctx, "store", "delete_config").WithFields(core.Field("key", key), core.Field("error", err.Error())).Error("删除失败")
 		return fmt.Errorf("%w: %v", core.ErrDeleteFailed, err)
 	}
 
-	m.log("delete_config").WithFields(logx.Field("key", key)).Info("删除成功")
+	m.logCtx.WithContext(ctx, "store", "delete_config").WithFields(core.Field("key", key)).Info("删除成功")
 	return nil
 }
 
-// AddPrefixWatcher 添加前缀监听器
-func (m *storeManager) AddPrefixWatcher(prefix string, callback core.PrefixWatchCallback) {
-	m.prefixWatchers.Store(prefix, callback)
-
-	// 触发已存在的配置
-	m.data.Range(func(_, value any) bool {
-		instanceMap := value.(*sync.Map)
-		instanceMap.Range(func(key, _ any) bool {
-			keyStr, ok := key.(string)
-			if ok && len(keyStr) >= len(prefix) && keyStr[:len(prefix)] == prefix {
-				callback(keyStr, core.EventTypePut)
+// RegisterConfig 运行时注册预加载配置
+func (m *storeManager) RegisterConfig(ctx context.Context, cfg core.WatchConfig) error {
+	if cfg.Path == "" {
+		return core.ErrConfigEmpty
+	}
+
+	if err := checkConfig(cfg, m.logCtx); err != nil {
+		return err
+	}
+
+	release, ok := m.group.Acquire()
+	if !ok {
+		return core.ErrConnectionClosed
+	}
+	defer release()
+
+	if _, err := m.addConfig(ctx, cfg, true); err != nil {
+		m.log("register_config").WithFields(core.Field("path", cfg.Path), core.Field("error", err.Error())).Error("注册失败")
+		return err
+	}
+
+	m.log("register_config").WithFields(core.Field("path", cfg.Path)).Info("注册成功")
+	return nil
+}
+
+// UnregisterConfig 注销预加载配置
+// 说明：
+//   - 等待路径的监听协程退出后再清空缓存，正在应用的事件不会在 DELETE 通知之后写回缓存
+//   - 因此不能在该路径触发的前缀监听器回调中调用，否则会等待自身退出
+func (m *storeManager) UnregisterConfig(path string) error {
+	if m.group.Closed() {
+		return core.ErrConnectionClosed
+	}
+
+	value, ok := m.data.LoadAndDelete(path)
+	if !ok {
+		return fmt.Errorf("%w: %s", core.ErrConfigNotFound, path)
+	}
+
+	cache := value.(*pathCache)
+	cache.cancel()
+	cache.running.Wait()
+	cache.markReady()
+	m.evictCache(cache)
+
+	m.log("unregister_config").WithFields(core.Field("path", path)).Info("注销成功")
+	return nil
+}
+
+// Stale 是否有路径缓存仍在使用尚未与 etcd 对齐的快照数据
+func (m *storeManager) Stale() bool {
+	var stale bool
+	m.rangeCaches(func(cache *pathCache) bool {
+		for _, st := range cache.streams {
+			if st.Stale() {
+				stale = true
+				return false
 			}
-			return true
-		})
+		}
+		return true
+	})
+	return stale
+}
+
+// Ready 预加载配置全部完成初始加载后关闭的通道
+func (m *storeManager) Ready() <-chan struct{} {
+	return m.ready
+}
+
+// AddPrefixWatcher 添加前缀监听器
+func (m *storeManager) AddPrefixWatcher(prefix string, callback core.PrefixWatchCallback, opts ...core.WatchOption) core.Subscription {
+	return m.addWatcher("add_prefix_watcher", prefix, func(change *core.ConfigChange) {
+		callback(change.Key, change.EventType)
+	}, opts...)
+}
+
+// AddConfigWatcher 添加携带变更前后实例的前缀监听器
+func (m *storeManager) AddConfigWatcher(prefix string, callback core.ConfigWatchCallback, opts ...core.WatchOption) core.Subscription {
+	return m.addWatcher("add_config_watcher", prefix, callback, opts...)
+}
+
+// Close 关闭配置存储管理器
+func (m *storeManager) Close(ctx context.Context) error {
+	err := m.group.Close(ctx)
+
+	// 结束所有前缀监听器的订阅
+	m.prefixWatchers.Range(func(id, value any) bool {
+		m.prefixWatchers.Delete(id)
+		value.(*prefixWatcher).remove(core.ErrConnectionClosed)
 		return true
 	})
 
-	m.log("add_prefix_watcher").WithFields(logx.Field("prefix", prefix)).Info("添加成功")
+	if err != nil {
+		m.log("close").WithFields(core.Field("error", err.Error())).Error("等待回调结束超时")
+		return err
+	}
+
+	m.log("close").Info("关闭成功")
+	return nil
 }
 
 // log 创建结构化日志
-func (m *storeManager) log(operation string) logx.Logger {
+func (m *storeManager) log(operation string) *core.LogEntry {
 	return m.logCtx.WithModule("store", operation)
 }
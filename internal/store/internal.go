@@ -2,97 +2,658 @@ package store
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/rezeropoint/etcdtrigger/v2/codec"
 	"github.com/rezeropoint/etcdtrigger/v2/core"
-	"github.com/zeromicro/go-zero/core/logx"
-	clientv3 "go.etcd.io/etcd/client/v3"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/dispatch"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/metrics"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/recovery"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/stream"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/subscription"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// initTypeStore 初始化类型存储
-func (m *storeManager) initTypeStore(configStruct any) {
+// pathCache 单个预加载路径的缓存
+type pathCache struct {
+	config    core.WatchConfig
+	typ       reflect.Type       // 绑定的结构体指针类型
+	codec     core.Codec         // 编解码器
+	entries   sync.Map           // 配置键 -> 结构体实例
+	count     atomic.Int64       // entries 的条目数，用于指标
+	cancel    context.CancelFunc // 停止该路径的监听
+	ready     chan struct{}      // 首次同步完成后关闭
+	readyOnce sync.Once
+	mu        sync.Mutex           // 保护各层的原始值与待通知的合并结果，串行化各层的合并与应用（不执行回调）
+	layersMu  sync.RWMutex         // 保护 layers 切片本身，matchCache 只读，不与合并互相等待
+	layers    []*layer             // 分层配置的各层，未设置 Layers 时为空
+	merged    bool                 // 所有层是否都已完成首次同步，此前只记录各层的原始值
+	pending   []*core.ConfigChange // 待通知的合并结果，按合并顺序排列
+	notifying bool                 // 是否有协程正在通知 pending
+	streams   []*stream.Stream     // 路径的监听流，创建后不再修改
+	running   sync.WaitGroup       // 路径的监听协程，注销时等待其退出后再清空缓存
+}
+
+// markReady 标记首次同步完成
+func (c *pathCache) markReady() {
+	c.readyOnce.Do(func() {
+		close(c.ready)
+	})
+}
+
+// checkConfig 校验预加载配置
+func checkConfig(cfg core.WatchConfig, logCtx *core.LogContext) error {
+	if err := checkStruct(cfg.Struct); err != nil {
+		return err
+	}
+	if len(cfg.Layers) == 0 {
+		return nil
+	}
+	if err := checkLayerCodec(codec.OrDefault(cfg.Codec)); err != nil {
+		return err
+	}
+	_, err := expandLayers(cfg.Layers, logCtx)
+	return err
+}
+
+// checkStruct 校验绑定的结构体实例
+func checkStruct(configStruct any) error {
 	t := reflect.TypeOf(configStruct)
-	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
-		panic("传入的必须是指向结构体的指针")
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: Struct 必须是指向结构体的指针", core.ErrInvalidConfig)
+	}
+	return nil
+}
+
+// addConfig 注册路径缓存并启动监听
+// 参数：
+//   - ctx: 初始同步的上下文
+//   - strict: 为 true 时初始同步失败会撤销注册并返回错误；为 false 时记录日志并在后台重试
+//
+// 说明：
+//   - 缓存按路径隔离，多个路径可以绑定同一结构体类型
+//   - 同一路径重复注册时返回 core.ErrConfigAlreadyExists
+func (m *storeManager) addConfig(ctx context.Context, cfg core.WatchConfig, strict bool) (*pathCache, error) {
+	watchCtx, cancel := context.WithCancel(m.group.Context())
+	cache := &pathCache{
+		config: cfg,
+		typ:    reflect.TypeOf(cfg.Struct),
+		codec:  codec.OrDefault(cfg.Codec),
+		cancel: cancel,
+		ready:  make(chan struct{}),
+	}
+	if _, loaded := m.data.LoadOrStore(cfg.Path, cache); loaded {
+		cancel()
+		return nil, fmt.Errorf("%w: %s", core.ErrConfigAlreadyExists, cfg.Path)
+	}
+
+	streams, err := m.newStreams(cache)
+	if err != nil {
+		m.data.Delete(cfg.Path)
+		cancel()
+		return nil, err
 	}
 
-	m.data.Store(t, &sync.Map{})
-	m.typeCaches.Store(t, reflect.New(t.Elem()).Interface())
+	cache.streams = streams
+
+	for _, st := range streams {
+		restored, err := st.Restore()
+		if err != nil {
+			m.log("restore_snapshot").WithFields(core.Field("path", cfg.Path), core.Field("error", err.Error())).Error("加载快照失败，忽略快照")
+		}
+		if restored {
+			// 快照数据先行提供服务，由监听从快照版本续接并对齐
+			continue
+		}
+
+		if err := st.Sync(ctx); err != nil {
+			if strict {
+				m.data.Delete(cfg.Path)
+				cancel()
+				m.evictCache(cache)
+				return nil, err
+			}
+			m.log("init_config").WithFields(core.Field("path", cfg.Path), core.Field("error", err.Error())).Error("初始化配置失败，将在后台重试")
+		}
+	}
+
+	for _, st := range streams {
+		cache.running.Add(1)
+		started := m.group.Go(func(context.Context) {
+			defer cache.running.Done()
+			m.watchConfigChanges(watchCtx, st)
+		})
+		if !started {
+			cache.running.Done()
+			m.data.Delete(cfg.Path)
+			cancel()
+			return nil, core.ErrConnectionClosed
+		}
+	}
+
+	return cache, nil
 }
 
-// initConfig 初始化配置
-func (m *storeManager) initConfig(ctx context.Context, cfg core.WatchConfig) error {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+// newStreams 创建路径缓存的监听流
+// 说明：
+//   - 未设置 Layers 时监听 Path 本身
+//   - 设置 Layers 时每层一个监听流，所有层完成首次同步后路径就绪
+func (m *storeManager) newStreams(cache *pathCache) ([]*stream.Stream, error) {
+	if len(cache.config.Layers) == 0 {
+		streamConfig := &stream.Config{
+			Prefix:   cache.config.Path,
+			OnState:  m.config.OnWatchState,
+			OnSync:   cache.markReady,
+			Snapshot: m.snapshots,
+			Metrics:  m.config.Metrics,
+			Tracer:   m.config.Tracer,
+		}
+		return []*stream.Stream{stream.New(m.backend, m.logCtx, streamConfig, func(event *core.WatchEvent) error {
+			m.applyEvent(cache, event)
+			return nil
+		})}, nil
+	}
 
-	resp, err := m.client.Get(ctx, cfg.Path, clientv3.WithPrefix())
+	prefixes, err := expandLayers(cache.config.Layers, m.logCtx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, kv := range resp.Kvs {
-		m.storeConfig(string(kv.Key), kv.Value, cfg.Struct)
+	layers := make([]*layer, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		layers = append(layers, &layer{prefix: prefix, docs: make(map[string][]byte)})
 	}
+	cache.layersMu.Lock()
+	cache.layers = layers
+	cache.layersMu.Unlock()
 
-	return nil
+	streams := make([]*stream.Stream, 0, len(prefixes))
+	for index, prefix := range prefixes {
+		streamConfig := &stream.Config{
+			Prefix:  prefix,
+			OnState: m.config.OnWatchState,
+			OnSync: func() {
+				m.layerSynced(cache, index)
+			},
+			Snapshot: m.snapshots,
+			Metrics:  m.config.Metrics,
+			Tracer:   m.config.Tracer,
+		}
+		streams = append(streams, stream.New(m.backend, m.logCtx, streamConfig, func(event *core.WatchEvent) error {
+			m.applyLayerEvent(cache, index, event)
+			return nil
+		}))
+	}
+	return streams, nil
 }
 
-// watchConfigChanges 监听配置变化
-func (m *storeManager) watchConfigChanges(ctx context.Context, cfg core.WatchConfig) {
-	watchChan := m.client.Watch(ctx, cfg.Path, clientv3.WithPrefix())
-	for watchResp := range watchChan {
-		for _, event := range watchResp.Events {
-			switch event.Type {
-			case clientv3.EventTypePut:
-				m.storeConfig(string(event.Kv.Key), event.Kv.Value, cfg.Struct)
-				m.notifyPrefixWatchers(string(event.Kv.Key), core.EventTypePut)
-			case clientv3.EventTypeDelete:
-				m.removeConfig(string(event.Kv.Key), cfg.Struct)
-				m.notifyPrefixWatchers(string(event.Kv.Key), core.EventTypeDelete)
+// evictCache 清空已移除的路径缓存
+// 说明：
+//   - 未被其他路径缓存持有的键会以 DELETE 事件通知前缀监听器
+func (m *storeManager) evictCache(cache *pathCache) {
+	cache.entries.Range(func(key, value any) bool {
+		keyStr := key.(string)
+		cache.entries.Delete(keyStr)
+
+		if !m.cached(keyStr) {
+			m.notifyPrefixWatchers(&core.ConfigChange{
+				Key:       keyStr,
+				EventType: core.EventTypeDelete,
+				Old:       value,
+			})
+		}
+		return true
+	})
+	cache.count.Store(0)
+	m.config.Metrics.CacheEntries(cache.config.Path, 0)
+}
+
+// cached 判断键是否仍被任一路径缓存持有
+func (m *storeManager) cached(key string) bool {
+	var found bool
+	m.rangeCaches(func(cache *pathCache) bool {
+		_, found = cache.entries.Load(key)
+		return !found
+	})
+	return found
+}
+
+// rangeCaches 遍历所有路径缓存
+func (m *storeManager) rangeCaches(fn func(cache *pathCache) bool) {
+	m.data.Range(func(_, value any) bool {
+		return fn(value.(*pathCache))
+	})
+}
+
+// matchCache 返回与 key 最长匹配的预加载路径缓存
+// 返回：
+//   - *pathCache: 匹配的路径缓存，未匹配时为 nil
+//   - bool: 是否匹配的是分层配置中的某一层（而非逻辑键路径）
+func (m *storeManager) matchCache(key string) (*pathCache, bool) {
+	var (
+		matched *pathCache
+		length  int
+		overlay bool
+	)
+	match := func(cache *pathCache, prefix string, isLayer bool) {
+		if strings.HasPrefix(key, prefix) && (matched == nil || len(prefix) > length) {
+			matched, length, overlay = cache, len(prefix), isLayer
+		}
+	}
+
+	m.rangeCaches(func(cache *pathCache) bool {
+		match(cache, cache.config.Path, false)
+
+		cache.layersMu.RLock()
+		for _, current := range cache.layers {
+			match(cache, current.prefix, true)
+		}
+		cache.layersMu.RUnlock()
+		return true
+	})
+	return matched, overlay
+}
+
+// rangeKeys 遍历匹配前缀的缓存键与实例，路径重叠时每个键只遍历一次
+func (m *storeManager) rangeKeys(prefix string, fn func(key string, instance any)) {
+	seen := make(map[string]struct{})
+	m.rangeCaches(func(cache *pathCache) bool {
+		cache.entries.Range(func(key, value any) bool {
+			keyStr := key.(string)
+			if _, ok := seen[keyStr]; ok || !strings.HasPrefix(keyStr, prefix) {
+				return true
 			}
+			seen[keyStr] = struct{}{}
+			fn(keyStr, value)
+			return true
+		})
+		return true
+	})
+}
+
+// addWatcher 注册前缀监听器并回放已存在的配置
+// 说明：
+//   - 启用 WithQueue 时回调在监听器独立的协程中执行，其余选项不适用于前缀监听器
+func (m *storeManager) addWatcher(operation, prefix string, callback core.ConfigWatchCallback, opts ...core.WatchOption) core.Subscription {
+	options := core.NewWatchOptions(opts...)
+	if err := options.Validate(); err != nil {
+		m.log(operation).WithFields(core.Field("prefix", prefix), core.Field("error", err.Error())).Error("订阅选项无效")
+		return subscription.Closed(err)
+	}
+
+	release, ok := m.group.Acquire()
+	if !ok {
+		m.log(operation).WithFields(core.Field("prefix", prefix), core.Field("error", core.ErrConnectionClosed.Error())).Error("管理器已关闭")
+		return subscription.Closed(core.ErrConnectionClosed)
+	}
+	defer release()
+
+	id := m.watcherSeq.Add(1)
+	ctx, cancel := context.WithCancel(m.group.Context())
+	watcher := &prefixWatcher{
+		id:       fmt.Sprintf("config-%d", id),
+		prefix:   prefix,
+		callback: callback,
+		metrics:  m.config.Metrics,
+		tracer:   m.config.Tracer,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	watcher.sub = subscription.New(func() {
+		m.prefixWatchers.Delete(id)
+		watcher.remove(core.ErrWatchCanceled)
+	})
+
+	if options.QueueSize > 0 {
+		watcher.queue = dispatch.NewQueue(&dispatch.Config{
+			Size:         options.QueueSize,
+			Policy:       options.QueuePolicy,
+			Prefix:       prefix,
+			Subscription: watcher.id,
+			Metrics:      m.config.Metrics,
+			Merge:        mergeChanges,
+		})
+		watcher.sub.SetStats(watcher.queue.Stats)
+
+		// 回调协程计入正在执行的回调，退出后订阅才会结束
+		watcher.running++
+		started := m.group.Go(func(context.Context) {
+			defer watcher.done()
+			m.runWatcher(watcher)
+		})
+		if !started {
+			watcher.done()
+			watcher.remove(core.ErrConnectionClosed)
+			return watcher.sub
 		}
 	}
+
+	m.prefixWatchers.Store(id, watcher)
+
+	// 触发已存在的配置
+	m.rangeKeys(prefix, func(key string, instance any) {
+		err := watcher.invoke(&core.ConfigChange{
+			Key:       key,
+			EventType: core.EventTypePut,
+			New:       instance,
+		})
+		if err != nil {
+			m.reportPanic(prefix, watcher.id, err)
+		}
+	})
+
+	m.log(operation).WithFields(core.Field("prefix", prefix), core.Field("subscription", watcher.id)).Info("添加成功")
+	return watcher.sub
 }
 
-// storeConfig 存储配置
-func (m *storeManager) storeConfig(key string, value []byte, configStruct any) {
-	t := reflect.TypeOf(configStruct)
-	cachedInstance, _ := m.typeCaches.Load(t)
-	instance := reflect.New(reflect.TypeOf(cachedInstance).Elem()).Interface()
+// runWatcher 依次执行前缀监听器队列中的回调，直到监听器移除或管理器关闭
+func (m *storeManager) runWatcher(watcher *prefixWatcher) {
+	for {
+		value, ok := watcher.queue.Pop(watcher.ctx)
+		if !ok || watcher.ctx.Err() != nil {
+			return
+		}
+		if err := watcher.call(value.(*core.ConfigChange)); err != nil {
+			m.reportPanic(watcher.prefix, watcher.id, err)
+		}
+	}
+}
 
-	if err := jsonIter.Unmarshal(value, instance); err != nil {
-		m.log("store_config").WithFields(logx.Field("key", key), logx.Field("error", err.Error())).Error("反序列化失败")
-		return
+// mergeChanges 合并同一键排队的变更，保留最早变更前的实例
+func mergeChanges(pending, latest any) any {
+	change := latest.(*core.ConfigChange)
+	merged := change.WithContext(change.Context())
+	merged.Old = pending.(*core.ConfigChange).Old
+	return merged
+}
+
+// watchConfigChanges 监听配置变化
+// 说明：
+//   - 初始化失败时在后台退避重试同步
+//   - 从快照的下一个版本开始监听，版本被压缩时自动重新同步，通道关闭时退避重连
+func (m *storeManager) watchConfigChanges(ctx context.Context, st *stream.Stream) {
+	_ = st.Run(ctx)
+}
+
+// applyEvent 将监听事件应用到缓存并通知前缀监听器
+func (m *storeManager) applyEvent(cache *pathCache, event *core.WatchEvent) {
+	m.notifyPrefixWatchers(m.updateCache(cache, event))
+}
+
+// updateCache 将监听事件应用到缓存
+// 返回：
+//   - *core.ConfigChange: 需要通知前缀监听器的变更
+//
+// 说明：
+//   - 反序列化或校验失败时保留缓存中的最后有效实例，返回 REJECT 事件
+func (m *storeManager) updateCache(cache *pathCache, event *core.WatchEvent) *core.ConfigChange {
+	change := &core.ConfigChange{
+		Key:       event.Key,
+		EventType: event.EventType,
+		Revision:  event.Revision,
 	}
+	change = change.WithContext(event.Context())
 
-	instanceMap, _ := m.data.Load(t)
-	typedMap := instanceMap.(*sync.Map)
-	typedMap.Store(key, instance)
+	switch event.EventType {
+	case core.EventTypePut:
+		instance, err := m.decodeConfig(cache, event.Key, event.Value)
+		if err == nil {
+			err = m.validateConfig(cache, event.Key, instance)
+		}
+		if err != nil {
+			change.EventType = core.EventTypeReject
+			change.Err = err
+			change.New = instance
+			change.Old, _ = cache.entries.Load(event.Key)
+			break
+		}
+		change.New = instance
+		change.Old = m.storeConfig(cache, event.Key, instance)
+	case core.EventTypeDelete:
+		change.Old = m.removeConfig(cache, event.Key)
+	}
+	return change
+}
+
+// decodeConfig 反序列化配置为路径绑定的结构体实例
+func (m *storeManager) decodeConfig(cache *pathCache, key string, value []byte) (any, error) {
+	instance := reflect.New(cache.typ.Elem()).Interface()
 
-	m.log("store_config").WithFields(logx.Field("key", key)).Info("更新成功")
+	if err := cache.codec.Unmarshal(value, instance); err != nil {
+		m.log("store_config").WithFields(core.Field("key", key), core.Field("codec", cache.codec.Name()), core.Field("error", err.Error())).Error("反序列化失败")
+		m.config.Metrics.UnmarshalFailure(cache.config.Path)
+		return nil, fmt.Errorf("%w: %v", core.ErrUnmarshalFailed, err)
+	}
+
+	return instance, nil
 }
 
-// removeConfig 删除配置
-func (m *storeManager) removeConfig(key string, configStruct any) {
-	t := reflect.TypeOf(configStruct)
+// validateConfig 校验配置实例
+func (m *storeManager) validateConfig(cache *pathCache, key string, instance any) error {
+	if err := m.validateInstance(cache, key, instance); err != nil {
+		m.log("store_config").WithFields(core.Field("key", key), core.Field("error", err.Error())).Error("校验失败，保留最后有效配置")
+		return err
+	}
+	return nil
+}
+
+// validateInstance 执行校验并恢复 panic
+// 参数：
+//   - cache: 匹配的路径缓存，为 nil 时只执行结构体自身的校验
+//
+// 说明：
+//   - 校验函数 panic 时视为校验失败，并按回调 panic 上报
+func (m *storeManager) validateInstance(cache *pathCache, key string, instance any) error {
+	err := recovery.Call(key, func() error {
+		return validate(cache, instance)
+	})
+	if errors.Is(err, core.ErrCallbackPanic) {
+		var path string
+		if cache != nil {
+			path = cache.config.Path
+		}
+		m.reportPanic(path, "", err)
+		err = fmt.Errorf("%w: %w", core.ErrValidationFailed, err)
+	}
+	return err
+}
+
+// normalizeConfig 将待写入的配置统一为指向结构体的指针
+// 参数：
+//   - cache: 匹配的路径缓存，为 nil 时不检查类型
+//
+// 返回：
+//   - any: 指针形式的配置，结构体值会复制到新分配的实例中，保证指针接收者的 Validate 也会执行
+//   - error: 与路径绑定的类型不一致时返回 core.ErrInvalidConfig
+func normalizeConfig(cache *pathCache, config any) (any, error) {
+	value := reflect.ValueOf(config)
+	if value.Kind() == reflect.Struct {
+		ptr := reflect.New(value.Type())
+		ptr.Elem().Set(value)
+		value = ptr
+	}
+
+	if cache != nil && (!value.IsValid() || value.Type() != cache.typ) {
+		return nil, fmt.Errorf("%w: 配置类型 %T 与路径 %s 绑定的类型 %v 不一致", core.ErrInvalidConfig, config, cache.config.Path, cache.typ)
+	}
+	if !value.IsValid() {
+		return config, nil
+	}
+	return value.Interface(), nil
+}
+
+// validate 依次执行结构体自身的 Validate 方法与路径的校验函数
+// 参数：
+//   - cache: 匹配的路径缓存，为 nil 时只执行结构体自身的校验
+func validate(cache *pathCache, instance any) error {
+	if validator, ok := instance.(core.Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", core.ErrValidationFailed, err)
+		}
+	}
+
+	if cache != nil && cache.config.Validate != nil {
+		if err := cache.config.Validate(instance); err != nil {
+			return fmt.Errorf("%w: %v", core.ErrValidationFailed, err)
+		}
+	}
+	return nil
+}
+
+// storeConfig 存储配置
+// 返回：
+//   - any: 被替换的旧实例，不存在时为 nil
+func (m *storeManager) storeConfig(cache *pathCache, key string, instance any) any {
+	old, loaded := cache.entries.Swap(key, instance)
+	if !loaded {
+		m.config.Metrics.CacheEntries(cache.config.Path, cache.count.Add(1))
+	}
 
-	instanceMap, _ := m.data.Load(t)
-	typedMap := instanceMap.(*sync.Map)
-	typedMap.Delete(key)
+	m.log("store_config").WithFields(core.Field("key", key)).Debug("更新成功")
+	return old
+}
 
-	m.log("remove_config").WithFields(logx.Field("key", key)).Info("删除成功")
+// removeConfig 删除配置
+// 返回：
+//   - any: 被删除的旧实例，不存在时为 nil
+func (m *storeManager) removeConfig(cache *pathCache, key string) any {
+	old, loaded := cache.entries.LoadAndDelete(key)
+	if loaded {
+		m.config.Metrics.CacheEntries(cache.config.Path, cache.count.Add(-1))
+	}
+
+	m.log("remove_config").WithFields(core.Field("key", key)).Debug("删除成功")
+	return old
 }
 
 // notifyPrefixWatchers 通知前缀监听器
-func (m *storeManager) notifyPrefixWatchers(key string, eventType core.EventType) {
-	m.prefixWatchers.Range(func(prefix, value any) bool {
-		if k, ok := prefix.(string); ok && len(key) >= len(k) && key[:len(k)] == k {
-			if callback, ok := value.(core.PrefixWatchCallback); ok {
-				callback(key, eventType)
+func (m *storeManager) notifyPrefixWatchers(change *core.ConfigChange) {
+	m.prefixWatchers.Range(func(_, value any) bool {
+		if watcher, ok := value.(*prefixWatcher); ok && watcher.matches(change.Key) {
+			if err := watcher.invoke(change); err != nil {
+				m.reportPanic(watcher.prefix, watcher.id, err)
 			}
 		}
 		return true
 	})
 }
+
+// reportPanic 记录回调 panic、计数并通知错误回调
+// 参数：
+//   - subscription: 前缀监听器的订阅 ID，Validate 的 panic 为空
+func (m *storeManager) reportPanic(prefix, subscription string, err error) {
+	var panicErr *core.PanicError
+	if !errors.As(err, &panicErr) {
+		return
+	}
+
+	m.log("callback").WithFields(core.Field("prefix", prefix), core.Field("subscription", subscription), core.Field("key", panicErr.Key), core.Field("panic", fmt.Sprint(panicErr.Value)), core.Field("stack", string(panicErr.Stack))).Error("回调 panic，已恢复")
+	m.config.Metrics.Panic(prefix, subscription)
+	if m.config.OnError != nil {
+		_ = recovery.Call(panicErr.Key, func() error {
+			m.config.OnError(prefix, panicErr)
+			return nil
+		})
+	}
+}
+
+// prefixWatcher 前缀监听器
+type prefixWatcher struct {
+	id       string // 订阅 ID，用作指标标签
+	prefix   string
+	callback core.ConfigWatchCallback
+	metrics  *metrics.Metrics // 指标记录器（可为 nil）
+	tracer   *tracing.Tracer  // 链路追踪（可为 nil）
+	queue    *dispatch.Queue  // 订阅队列，未启用时为 nil
+	ctx      context.Context  // 移除或管理器关闭时取消，用于停止排队与回调协程
+	cancel   context.CancelFunc
+	sub      *subscription.Subscription
+	mu       sync.Mutex
+	running  int   // 正在执行的回调数（启用队列时包括回调协程）
+	removed  bool  // 是否已移除
+	reason   error // 移除原因
+}
+
+// matches 判断键是否匹配监听前缀
+func (w *prefixWatcher) matches(key string) bool {
+	return strings.HasPrefix(key, w.prefix)
+}
+
+// invoke 执行回调或投递到订阅队列，已移除的监听器不再执行
+// 返回：
+//   - error: 回调 panic 时返回 *core.PanicError，其余情况为 nil
+//
+// 说明：
+//   - OverflowBlock 队列已满时阻塞，直到有空位或监听器移除
+func (w *prefixWatcher) invoke(change *core.ConfigChange) error {
+	w.mu.Lock()
+	if w.removed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.running++
+	w.mu.Unlock()
+
+	defer w.done()
+
+	if w.queue != nil {
+		w.queue.Push(w.ctx, change.Key, change)
+		return nil
+	}
+	return w.call(change)
+}
+
+// call 执行回调并恢复 panic
+// 说明：
+//   - 启用链路追踪时回调在变更上下文的子 Span 中执行
+func (w *prefixWatcher) call(change *core.ConfigChange) error {
+	ctx, span := w.tracer.Start(change.Context(), "etcdtrigger.callback", trace.SpanKindInternal, change.Key)
+	change = change.WithContext(ctx)
+
+	start := time.Now()
+	err := recovery.Call(change.Key, func() error {
+		w.callback(change)
+		return nil
+	})
+	w.metrics.Callback(w.prefix, w.id, time.Since(start), err)
+	tracing.End(span, err)
+	return err
+}
+
+// done 回调结束，移除后最后一个回调结束时结束订阅
+func (w *prefixWatcher) done() {
+	w.mu.Lock()
+	w.running--
+	finished := w.removed && w.running == 0
+	w.mu.Unlock()
+
+	if finished {
+		w.sub.Finish(w.reason)
+	}
+}
+
+// remove 移除监听器，没有正在执行的回调时立即结束订阅
+func (w *prefixWatcher) remove(reason error) {
+	w.mu.Lock()
+	if w.removed {
+		w.mu.Unlock()
+		return
+	}
+	w.removed = true
+	w.reason = reason
+	finished := w.running == 0
+	w.mu.Unlock()
+
+	w.cancel()
+
+	if finished {
+		w.sub.Finish(reason)
+	}
+}
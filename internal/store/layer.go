@@ -0,0 +1,241 @@
+package store
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// 分层路径模板占位符
+const (
+	placeholderService = "{service}"
+	placeholderPod     = "{pod}"
+)
+
+// layer 分层配置中的单层
+type layer struct {
+	prefix   string            // 展开占位符后的层前缀
+	docs     map[string][]byte // 相对层前缀的后缀 -> 原始值
+	revision int64             // 最后应用的事件版本
+	synced   bool              // 是否完成过首次同步
+}
+
+// expandLayers 展开分层路径模板
+// 返回：
+//   - []string: 展开后的层前缀，优先级从低到高
+//   - error: 占位符对应的服务名或 Pod 名为空时返回 core.ErrInvalidConfig
+func expandLayers(templates []string, logCtx *core.LogContext) ([]string, error) {
+	prefixes := make([]string, 0, len(templates))
+	for _, template := range templates {
+		if strings.Contains(template, placeholderService) && logCtx.ServiceName == "" {
+			return nil, fmt.Errorf("%w: 分层路径 %s 需要 ServiceName", core.ErrInvalidConfig, template)
+		}
+		if strings.Contains(template, placeholderPod) && logCtx.PodName == "" {
+			return nil, fmt.Errorf("%w: 分层路径 %s 需要 PodName", core.ErrInvalidConfig, template)
+		}
+
+		prefix := strings.NewReplacer(placeholderService, logCtx.ServiceName, placeholderPod, logCtx.PodName).Replace(template)
+		if prefix == "" {
+			return nil, fmt.Errorf("%w: 分层路径不能为空", core.ErrInvalidConfig)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// checkLayerCodec 校验编解码器能否用于分层合并
+// 返回：
+//   - error: 无法编解码 map[string]any（如 Protobuf）时返回 core.ErrInvalidConfig
+func checkLayerCodec(c core.Codec) error {
+	data, err := c.Marshal(map[string]any{"layer": true})
+	if err == nil {
+		var tree map[string]any
+		err = c.Unmarshal(data, &tree)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: 编解码器 %s 无法编解码通用对象，不支持分层配置: %v", core.ErrInvalidConfig, c.Name(), err)
+	}
+	return nil
+}
+
+// applyLayerEvent 更新单层的原始值，并重新合并对应的逻辑键
+// 说明：
+//   - 逻辑键为 WatchConfig.Path 加上相对层前缀的后缀
+//   - 所有层完成首次同步前只记录原始值，由 layerSynced 统一合并
+//   - 合并与缓存更新在 cache.mu 内完成，回调在释放锁后按合并顺序执行，回调中可以调用 PutConfig 等写入操作
+func (m *storeManager) applyLayerEvent(cache *pathCache, index int, event *core.WatchEvent) {
+	cache.mu.Lock()
+	current := cache.layers[index]
+	current.revision = event.Revision
+	suffix := strings.TrimPrefix(event.Key, current.prefix)
+	switch event.EventType {
+	case core.EventTypePut:
+		current.docs[suffix] = event.Value
+	case core.EventTypeDelete:
+		delete(current.docs, suffix)
+	}
+
+	if cache.merged {
+		cache.pending = append(cache.pending, m.mergeChange(cache, suffix, event))
+	}
+	cache.mu.Unlock()
+
+	m.notifyLayerChanges(cache)
+}
+
+// layerSynced 标记单层完成首次同步
+// 说明：
+//   - 所有层都完成后合并并通知全部逻辑键，随后标记路径就绪，启动期间不会通知只包含部分层的结果
+func (m *storeManager) layerSynced(cache *pathCache, index int) {
+	cache.mu.Lock()
+	cache.layers[index].synced = true
+	if cache.merged {
+		cache.mu.Unlock()
+		return
+	}
+
+	var revision int64
+	suffixes := make(map[string]struct{})
+	for _, current := range cache.layers {
+		if !current.synced {
+			cache.mu.Unlock()
+			return
+		}
+		revision = max(revision, current.revision)
+		for suffix := range current.docs {
+			suffixes[suffix] = struct{}{}
+		}
+	}
+
+	cache.merged = true
+	event := &core.WatchEvent{Revision: revision}
+	for _, suffix := range slices.Sorted(maps.Keys(suffixes)) {
+		cache.pending = append(cache.pending, m.mergeChange(cache, suffix, event))
+	}
+	cache.mu.Unlock()
+
+	m.notifyLayerChanges(cache)
+	cache.markReady()
+}
+
+// mergeChange 合并逻辑键并应用到缓存，需持有 cache.mu
+// 参数：
+//   - event: 触发合并的层事件，提供版本与链路上下文
+//
+// 返回：
+//   - *core.ConfigChange: 需要通知前缀监听器的变更
+//
+// 说明：
+//   - 所有层都不存在该后缀时以 DELETE 应用到缓存
+//   - 合并失败时返回 REJECT，缓存保留最后有效的实例
+func (m *storeManager) mergeChange(cache *pathCache, suffix string, event *core.WatchEvent) *core.ConfigChange {
+	merged := (&core.WatchEvent{
+		Key:      cache.config.Path + suffix,
+		Revision: event.Revision,
+	}).WithContext(event.Context())
+
+	value, found, err := m.mergeLayers(cache, suffix)
+	switch {
+	case err != nil:
+		old, _ := cache.entries.Load(merged.Key)
+		m.log("merge_layers").WithFields(core.Field("key", merged.Key), core.Field("error", err.Error())).Error("合并分层配置失败，保留最后有效配置")
+		return (&core.ConfigChange{
+			Key:       merged.Key,
+			EventType: core.EventTypeReject,
+			Old:       old,
+			Revision:  event.Revision,
+			Err:       err,
+		}).WithContext(event.Context())
+	case !found:
+		merged.EventType = core.EventTypeDelete
+	default:
+		merged.EventType = core.EventTypePut
+		merged.Value = value
+	}
+
+	return m.updateCache(cache, merged)
+}
+
+// notifyLayerChanges 按合并顺序通知待通知的合并结果
+// 说明：
+//   - 不持有 cache.mu 执行回调，同一时刻只有一个协程通知，其他层在此期间产生的结果由它按顺序一并通知
+func (m *storeManager) notifyLayerChanges(cache *pathCache) {
+	cache.mu.Lock()
+	if cache.notifying {
+		cache.mu.Unlock()
+		return
+	}
+	cache.notifying = true
+
+	for len(cache.pending) > 0 {
+		changes := cache.pending
+		cache.pending = nil
+		cache.mu.Unlock()
+
+		for _, change := range changes {
+			m.notifyPrefixWatchers(change)
+		}
+		cache.mu.Lock()
+	}
+	cache.notifying = false
+	cache.mu.Unlock()
+}
+
+// mergeLayers 按优先级从低到高深度合并各层中后缀对应的值
+// 返回：
+//   - []byte: 合并后按路径编解码器编码的值
+//   - bool: 是否有任一层存在该后缀
+//   - error: 任一层解码或合并结果编码失败时返回 core.ErrUnmarshalFailed 或 core.ErrMarshalFailed
+//
+// 说明：
+//   - 对象按字段递归合并，其余值（包括数组）由高优先级层整体覆盖
+func (m *storeManager) mergeLayers(cache *pathCache, suffix string) ([]byte, bool, error) {
+	var (
+		tree  map[string]any
+		found bool
+	)
+	for _, current := range cache.layers {
+		doc, ok := current.docs[suffix]
+		if !ok {
+			continue
+		}
+		found = true
+
+		var overlay map[string]any
+		if err := cache.codec.Unmarshal(doc, &overlay); err != nil {
+			return nil, true, fmt.Errorf("%w: %s%s: %v", core.ErrUnmarshalFailed, current.prefix, suffix, err)
+		}
+		tree = mergeTree(tree, overlay)
+	}
+
+	if !found {
+		return nil, false, nil
+	}
+
+	value, err := cache.codec.Marshal(tree)
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: %v", core.ErrMarshalFailed, err)
+	}
+	return value, true, nil
+}
+
+// mergeTree 将 src 深度合并到 dst
+func mergeTree(dst, src map[string]any) map[string]any {
+	if dst == nil {
+		dst = make(map[string]any, len(src))
+	}
+
+	for key, value := range src {
+		srcChild, srcIsMap := value.(map[string]any)
+		dstChild, dstIsMap := dst[key].(map[string]any)
+		if srcIsMap && dstIsMap {
+			dst[key] = mergeTree(dstChild, srcChild)
+			continue
+		}
+		dst[key] = value
+	}
+	return dst
+}
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/codec"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/testutil"
+)
+
+type layeredConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func TestLayerCallbackCanWrite(t *testing.T) {
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem, &Config{
+		Configs: []core.WatchConfig{{
+			Path:   "/app/",
+			Struct: &layeredConfig{},
+			Layers: []string{"/global/", "/svc/{service}/"},
+		}},
+	})
+
+	written := make(chan error, 1)
+	m.AddConfigWatcher("/app/", func(change *core.ConfigChange) {
+		if change.EventType != core.EventTypePut || change.Old != nil {
+			return
+		}
+		// 回调在层的监听协程中执行，写入同样匹配分层路径
+		written <- m.PutConfig(context.Background(), "/svc/api/db", map[string]any{"port": 2})
+	})
+
+	put(t, mem, "/global/db", `{"host":"a","port":1}`)
+
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatalf("PutConfig: %v", err)
+		}
+	case <-time.After(testutil.Timeout):
+		t.Fatal("回调中的 PutConfig 未返回")
+	}
+
+	var got layeredConfig
+	testutil.Eventually(t, func() bool {
+		return m.GetConfig("/app/db", &got) && got == layeredConfig{Host: "a", Port: 2}
+	}, "合并结果为 %+v", got)
+}
+
+func TestLayerStartupMergesAllLayers(t *testing.T) {
+	mem := backend.NewMemory()
+	put(t, mem, "/global/db", `{"host":"global","port":1}`)
+	put(t, mem, "/svc/api/db", `{"port":2}`)
+
+	m := newTestManager(t, mem, &Config{})
+	ch := changes(m, "/app/")
+
+	err := m.RegisterConfig(context.Background(), core.WatchConfig{
+		Path:   "/app/",
+		Struct: &layeredConfig{},
+		Layers: []string{"/global/", "/svc/{service}/"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterConfig: %v", err)
+	}
+
+	// 所有层完成首次同步后只通知一次完整的合并结果
+	change := nextChange(t, ch)
+	if got := change.New.(*layeredConfig); change.EventType != core.EventTypePut || *got != (layeredConfig{Host: "global", Port: 2}) {
+		t.Fatalf("启动时的变更 = %s %+v, want PUT {global 2}", change.EventType, *got)
+	}
+	select {
+	case change := <-ch:
+		t.Fatalf("多余的变更 %s %+v", change.EventType, change.New)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLayerCallbackDoesNotBlockMerge(t *testing.T) {
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem, &Config{
+		Configs: []core.WatchConfig{{
+			Path:   "/app/",
+			Struct: &layeredConfig{},
+			Layers: []string{"/global/", "/svc/{service}/"},
+		}},
+	})
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	m.AddConfigWatcher("/app/", func(change *core.ConfigChange) {
+		if change.Key == "/app/slow" {
+			close(entered)
+			<-release
+		}
+	})
+
+	put(t, mem, "/global/slow", `{"port":1}`)
+	select {
+	case <-entered:
+	case <-time.After(testutil.Timeout):
+		t.Fatal("回调未执行")
+	}
+
+	// 回调阻塞期间其他层的变更仍然合并进缓存，读取缓存不会等待回调
+	put(t, mem, "/svc/api/db", `{"host":"svc","port":2}`)
+	var got layeredConfig
+	testutil.Eventually(t, func() bool {
+		return m.GetConfig("/app/db", &got) && got == layeredConfig{Host: "svc", Port: 2}
+	}, "回调阻塞期间合并结果为 %+v", got)
+}
+
+func TestCheckConfigLayerCodec(t *testing.T) {
+	tests := []struct {
+		name    string
+		codec   core.Codec
+		wantErr bool
+	}{
+		{name: "default", codec: nil},
+		{name: "json", codec: codec.JSON},
+		{name: "yaml", codec: codec.YAML},
+		{name: "toml", codec: codec.TOML},
+		{name: "msgpack", codec: codec.Msgpack},
+		{name: "mapping-json", codec: codec.MappingJSON},
+		{name: "protobuf", codec: codec.Protobuf, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkConfig(core.WatchConfig{
+				Path:   "/app/",
+				Struct: &layeredConfig{},
+				Codec:  tt.codec,
+				Layers: []string{"/global/"},
+			}, &core.LogContext{})
+			if got := errors.Is(err, core.ErrInvalidConfig); got != tt.wantErr {
+				t.Fatalf("checkConfig() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeTree(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  map[string]any
+		src  map[string]any
+		want map[string]any
+	}{
+		{
+			name: "nil dst",
+			src:  map[string]any{"a": 1.0},
+			want: map[string]any{"a": 1.0},
+		},
+		{
+			name: "override scalar",
+			dst:  map[string]any{"a": 1.0, "b": "x"},
+			src:  map[string]any{"a": 2.0},
+			want: map[string]any{"a": 2.0, "b": "x"},
+		},
+		{
+			name: "nested objects merge",
+			dst:  map[string]any{"db": map[string]any{"host": "a", "port": 1.0}},
+			src:  map[string]any{"db": map[string]any{"port": 2.0}},
+			want: map[string]any{"db": map[string]any{"host": "a", "port": 2.0}},
+		},
+		{
+			name: "arrays replaced",
+			dst:  map[string]any{"tags": []any{"a", "b"}},
+			src:  map[string]any{"tags": []any{"c"}},
+			want: map[string]any{"tags": []any{"c"}},
+		},
+		{
+			name: "object replaces scalar",
+			dst:  map[string]any{"db": "a"},
+			src:  map[string]any{"db": map[string]any{"host": "b"}},
+			want: map[string]any{"db": map[string]any{"host": "b"}},
+		},
+		{
+			name: "null overrides",
+			dst:  map[string]any{"db": map[string]any{"host": "a"}},
+			src:  map[string]any{"db": nil},
+			want: map[string]any{"db": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeTree(tt.dst, tt.src); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("mergeTree() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayerOverrides(t *testing.T) {
+	mem := backend.NewMemory()
+	put(t, mem, "/global/db", `{"host":"global","port":1}`)
+	put(t, mem, "/svc/api/db", `{"port":2}`)
+	put(t, mem, "/svc/other/db", `{"host":"other"}`)
+
+	m := newTestManager(t, mem, &Config{
+		Configs: []core.WatchConfig{{
+			Path:   "/app/",
+			Struct: &layeredConfig{},
+			Layers: []string{"/global/", "/svc/{service}/", "/pod/{pod}/"},
+		}},
+	})
+	ch := changes(m, "/app/")
+
+	expect := func(want layeredConfig) {
+		t.Helper()
+
+		var got layeredConfig
+		testutil.Eventually(t, func() bool {
+			return m.GetConfig("/app/db", &got) && got == want
+		}, "合并结果为 %+v, want %+v", got, want)
+	}
+
+	// 初始同步合并全局层与本服务层，忽略其他服务
+	expect(layeredConfig{Host: "global", Port: 2})
+	nextChange(t, ch) // 添加监听器时回放
+
+	steps := []struct {
+		name   string
+		key    string
+		value  string // 为空时删除
+		want   layeredConfig
+		reject bool
+	}{
+		{name: "pod overrides service", key: "/pod/api-0/db", value: `{"port":3}`, want: layeredConfig{Host: "global", Port: 3}},
+		{name: "lower layer change visible", key: "/global/db", value: `{"host":"updated","port":1}`, want: layeredConfig{Host: "updated", Port: 3}},
+		{name: "malformed layer rejected", key: "/svc/api/db", value: `{"port":`, want: layeredConfig{Host: "updated", Port: 3}, reject: true},
+		{name: "delete pod falls back", key: "/pod/api-0/db", want: layeredConfig{Host: "updated", Port: 1}},
+	}
+
+	for _, step := range steps {
+		if step.value == "" {
+			if _, err := mem.Delete(context.Background(), step.key, false); err != nil {
+				t.Fatalf("%s: Delete: %v", step.name, err)
+			}
+		} else {
+			put(t, mem, step.key, step.value)
+		}
+
+		change := nextChange(t, ch)
+		if got := change.EventType == core.EventTypeReject; got != step.reject {
+			t.Fatalf("%s: 变更 = %s (%v), want reject %v", step.name, change.EventType, change.Err, step.reject)
+		}
+		if change.Key != "/app/db" {
+			t.Fatalf("%s: 变更的键 = %s, want /app/db", step.name, change.Key)
+		}
+		expect(step.want)
+
+		if step.reject {
+			// 修复该层以便后续步骤继续合并
+			put(t, mem, step.key, `{}`)
+			nextChange(t, ch)
+		}
+	}
+
+	// 所有层都删除后以 DELETE 通知
+	if _, err := mem.Delete(context.Background(), "/global/db", false); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := mem.Delete(context.Background(), "/svc/api/db", false); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	nextChange(t, ch)
+	if change := nextChange(t, ch); change.EventType != core.EventTypeDelete {
+		t.Fatalf("变更 = %s, want DELETE", change.EventType)
+	}
+}
@@ -4,19 +4,32 @@ import (
 	"context"
 
 	"github.com/rezeropoint/etcdtrigger/v2/core"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 // Manager 配置存储管理器接口
 type Manager interface {
-	GetConfig(key string, result any) bool                           // 从缓存获取配置（强类型）
-	GetAllKeys(prefix string) []string                               // 获取指定前缀的所有键
-	PutConfig(ctx context.Context, key string, config any) error     // 写入配置（自动序列化）
-	DeleteConfig(ctx context.Context, key string) error              // 删除配置
-	AddPrefixWatcher(prefix string, callback core.PrefixWatchCallback) // 添加前缀监听器
+	GetConfig(key string, result any) bool                                                                         // 从缓存获取配置（强类型）
+	GetAllKeys(prefix string) []string                                                                             // 获取指定前缀的所有键
+	PutConfig(ctx context.Context, key string, config any) error                                                   // 写入配置（自动序列化）
+	DeleteConfig(ctx context.Context, key string) error                                                            // 删除配置
+	RegisterConfig(ctx context.Context, cfg core.WatchConfig) error                                                // 运行时注册预加载配置
+	UnregisterConfig(path string) error                                                                            // 注销预加载配置
+	AddPrefixWatcher(prefix string, callback core.PrefixWatchCallback, opts ...core.WatchOption) core.Subscription // 添加前缀监听器
+	AddConfigWatcher(prefix string, callback core.ConfigWatchCallback, opts ...core.WatchOption) core.Subscription // 添加携带变更前后实例的前缀监听器
+	Ready() <-chan struct{}                                                                                        // 预加载配置全部完成初始加载后关闭
+	Stale() bool                                                                                                   // 是否仍在使用尚未与 etcd 对齐的快照数据
+	Close(ctx context.Context) error                                                                               // 关闭并等待回调结束
 }
 
 // NewManager 创建配置存储管理器
-func NewManager(client *clientv3.Client, logCtx *core.LogContext, config *Config) Manager {
-	return newManager(client, logCtx, config)
+// 说明：
+//   - ctx 控制预加载配置的初始加载
+//   - FailFast 为 true 时任一预加载配置初始加载失败即返回错误
+//   - 否则加载失败的配置在后台重试，完成后 Ready 关闭
+func NewManager(ctx context.Context, backend core.Backend, logCtx *core.LogContext, config *Config) (Manager, error) {
+	manager, err := newManager(ctx, backend, logCtx, config)
+	if err != nil {
+		return nil, err
+	}
+	return manager, nil
 }
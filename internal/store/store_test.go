@@ -0,0 +1,481 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/codec"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/testutil"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestManager 创建配置存储管理器并等待预加载配置就绪，测试结束时关闭
+func newTestManager(t *testing.T, backend core.Backend, config *Config) *storeManager {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testutil.Timeout)
+	defer cancel()
+
+	logCtx := &core.LogContext{ServiceName: "api", PodName: "api-0"}
+	m, err := newManager(ctx, backend, logCtx, config)
+	if err != nil {
+		t.Fatalf("newManager: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.Timeout)
+		defer cancel()
+		if err := m.Close(ctx); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+
+	select {
+	case <-m.Ready():
+	case <-ctx.Done():
+		t.Fatal("预加载配置未就绪")
+	}
+	return m
+}
+
+// put 直接写入内存后端
+func put(t *testing.T, mem *backend.Memory, key, value string) {
+	t.Helper()
+
+	if _, err := mem.Put(context.Background(), key, []byte(value), 0); err != nil {
+		t.Fatalf("Put %s: %v", key, err)
+	}
+}
+
+type serverConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func (c *serverConfig) Validate() error {
+	if c.Port <= 0 {
+		return errors.New("port 必须为正数")
+	}
+	return nil
+}
+
+// otherConfig 与 serverConfig 字段相同的另一类型
+type otherConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// validateHost 拒绝保留的主机名，遇到 "panic" 时 panic
+func validateHost(config any) error {
+	switch config.(*serverConfig).Host {
+	case "reserved":
+		return errors.New("保留的主机名")
+	case "panic":
+		panic("validator panic")
+	}
+	return nil
+}
+
+// changes 收集配置变更
+func changes(m *storeManager, prefix string) <-chan *core.ConfigChange {
+	ch := make(chan *core.ConfigChange, 100)
+	m.AddConfigWatcher(prefix, func(change *core.ConfigChange) {
+		ch <- change
+	})
+	return ch
+}
+
+// nextChange 读取下一个配置变更，超时终止测试
+func nextChange(t *testing.T, ch <-chan *core.ConfigChange) *core.ConfigChange {
+	t.Helper()
+
+	select {
+	case change := <-ch:
+		return change
+	case <-time.After(testutil.Timeout):
+		t.Fatal("未收到配置变更")
+		return nil
+	}
+}
+
+func TestApplyEventRejects(t *testing.T) {
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem, &Config{
+		Configs: []core.WatchConfig{{Path: "/app/", Struct: &serverConfig{}, Validate: validateHost}},
+	})
+	ch := changes(m, "/app/")
+
+	put(t, mem, "/app/db", `{"host":"a","port":1}`)
+	if change := nextChange(t, ch); change.EventType != core.EventTypePut || change.Old != nil {
+		t.Fatalf("变更 = %s (old %v), want 新增的 PUT", change.EventType, change.Old)
+	}
+
+	last := serverConfig{Host: "a", Port: 1}
+	tests := []struct {
+		name    string
+		value   string
+		wantErr error
+	}{
+		{name: "malformed", value: `{"host":`, wantErr: core.ErrUnmarshalFailed},
+		{name: "struct validate", value: `{"host":"b","port":0}`, wantErr: core.ErrValidationFailed},
+		{name: "validate func", value: `{"host":"reserved","port":1}`, wantErr: core.ErrValidationFailed},
+		{name: "validate func panic", value: `{"host":"panic","port":1}`, wantErr: core.ErrCallbackPanic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			put(t, mem, "/app/db", tt.value)
+
+			change := nextChange(t, ch)
+			if change.EventType != core.EventTypeReject || !errors.Is(change.Err, tt.wantErr) {
+				t.Fatalf("变更 = %s (%v), want REJECT (%v)", change.EventType, change.Err, tt.wantErr)
+			}
+			if old, ok := change.Old.(*serverConfig); !ok || *old != last {
+				t.Fatalf("Old = %v, want 最后有效的实例", change.Old)
+			}
+
+			// 缓存保留最后有效的实例
+			var got serverConfig
+			if !m.GetConfig("/app/db", &got) || got != last {
+				t.Fatalf("GetConfig = %+v, want %+v", got, last)
+			}
+		})
+	}
+
+	// 恢复有效值后正常更新
+	put(t, mem, "/app/db", `{"host":"c","port":2}`)
+	if change := nextChange(t, ch); change.EventType != core.EventTypePut || *change.New.(*serverConfig) != (serverConfig{Host: "c", Port: 2}) {
+		t.Fatalf("变更 = %s (new %v), want PUT", change.EventType, change.New)
+	}
+}
+
+func TestMappingCodecValidation(t *testing.T) {
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem, &Config{
+		Configs: []core.WatchConfig{{Path: "/app/", Struct: &serverConfig{}, Codec: codec.MappingJSON}},
+	})
+	ch := changes(m, "/app/")
+
+	put(t, mem, "/app/db", `{"host":"a","port":1}`)
+	if change := nextChange(t, ch); change.EventType != core.EventTypePut {
+		t.Fatalf("变更 = %s, want PUT", change.EventType)
+	}
+
+	// 校验失败报告为 ErrValidationFailed，而不是反序列化失败
+	put(t, mem, "/app/db", `{"host":"b","port":0}`)
+	change := nextChange(t, ch)
+	if change.EventType != core.EventTypeReject || !errors.Is(change.Err, core.ErrValidationFailed) || errors.Is(change.Err, core.ErrUnmarshalFailed) {
+		t.Fatalf("变更 = %s (%v), want REJECT (ErrValidationFailed)", change.EventType, change.Err)
+	}
+}
+
+func TestPutConfigValidation(t *testing.T) {
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem, &Config{
+		Configs: []core.WatchConfig{{Path: "/app/", Struct: &serverConfig{}, Validate: validateHost}},
+	})
+
+	tests := []struct {
+		name    string
+		config  any
+		wantErr error
+	}{
+		{name: "pointer", config: &serverConfig{Host: "a", Port: 1}},
+		{name: "value", config: serverConfig{Host: "a", Port: 1}},
+		{name: "value fails pointer validate", config: serverConfig{Host: "a"}, wantErr: core.ErrValidationFailed},
+		{name: "validate func", config: &serverConfig{Host: "reserved", Port: 1}, wantErr: core.ErrValidationFailed},
+		{name: "validate func panic", config: &serverConfig{Host: "panic", Port: 1}, wantErr: core.ErrCallbackPanic},
+		{name: "other type", config: &otherConfig{Host: "a", Port: 1}, wantErr: core.ErrInvalidConfig},
+		{name: "map", config: map[string]any{"host": "a", "port": 1}, wantErr: core.ErrInvalidConfig},
+		{name: "nil", config: nil, wantErr: core.ErrInvalidConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := mem.Revision()
+
+			err := m.PutConfig(context.Background(), "/app/db", tt.config)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("PutConfig: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PutConfig = %v, want %v", err, tt.wantErr)
+			}
+			if mem.Revision() != before {
+				t.Fatal("校验失败的配置被写入")
+			}
+		})
+	}
+
+	// 未匹配任何预加载路径时不检查类型
+	if err := m.PutConfig(context.Background(), "/other/db", map[string]any{"host": "a"}); err != nil {
+		t.Fatalf("PutConfig 未匹配的路径: %v", err)
+	}
+}
+
+// unavailableBackend 读取失败且监听不返回任何响应的后端，模拟 etcd 不可用
+type unavailableBackend struct {
+	*backend.Memory
+}
+
+func (b unavailableBackend) Get(context.Context, string, bool) (*core.GetResult, error) {
+	return nil, errors.New("etcd 不可用")
+}
+
+func (b unavailableBackend) Watch(ctx context.Context, _ string, _ core.WatchRequest) <-chan *core.WatchResponse {
+	ch := make(chan *core.WatchResponse)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	mem := backend.NewMemory()
+	config := &Config{
+		Configs:     []core.WatchConfig{{Path: "/app/", Struct: &serverConfig{}}},
+		SnapshotDir: t.TempDir(),
+	}
+
+	put(t, mem, "/app/db", `{"host":"a","port":1}`)
+
+	// 首次启动从 etcd 同步并在关闭时写入快照
+	first, err := newManager(context.Background(), mem, &core.LogContext{}, config)
+	if err != nil {
+		t.Fatalf("newManager: %v", err)
+	}
+	<-first.Ready()
+	if err := first.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// etcd 不可用时从快照恢复
+	m := newTestManager(t, unavailableBackend{mem}, &Config{
+		Configs:     config.Configs,
+		SnapshotDir: config.SnapshotDir,
+		FailFast:    true,
+	})
+
+	var got serverConfig
+	if !m.GetConfig("/app/db", &got) || got != (serverConfig{Host: "a", Port: 1}) {
+		t.Fatalf("GetConfig = %+v, want 快照中的配置", got)
+	}
+	if !m.Stale() {
+		t.Fatal("从快照恢复后 Stale 为 false")
+	}
+}
+
+func TestUnregisterConfig(t *testing.T) {
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem, &Config{})
+
+	if err := m.RegisterConfig(context.Background(), core.WatchConfig{Path: "/app/", Struct: &serverConfig{}}); err != nil {
+		t.Fatalf("RegisterConfig: %v", err)
+	}
+	if err := m.RegisterConfig(context.Background(), core.WatchConfig{Path: "/app/", Struct: &serverConfig{}}); !errors.Is(err, core.ErrConfigAlreadyExists) {
+		t.Fatalf("重复 RegisterConfig = %v, want ErrConfigAlreadyExists", err)
+	}
+
+	put(t, mem, "/app/db", `{"host":"a","port":1}`)
+	var got serverConfig
+	testutil.Eventually(t, func() bool { return m.GetConfig("/app/db", &got) }, "配置未加载")
+	ch := changes(m, "/app/")
+	nextChange(t, ch) // 添加监听器时回放
+
+	if err := m.UnregisterConfig("/app/"); err != nil {
+		t.Fatalf("UnregisterConfig: %v", err)
+	}
+	if change := nextChange(t, ch); change.EventType != core.EventTypeDelete || change.Key != "/app/db" {
+		t.Fatalf("变更 = %s %s, want DELETE /app/db", change.EventType, change.Key)
+	}
+	if keys := m.GetAllKeys("/app/"); len(keys) != 0 {
+		t.Fatalf("注销后仍有键 %v", keys)
+	}
+
+	// 注销后的写入不再进入缓存
+	put(t, mem, "/app/db", `{"host":"b","port":1}`)
+	select {
+	case change := <-ch:
+		t.Fatalf("注销后收到变更 %s %s", change.EventType, change.Key)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := m.UnregisterConfig("/app/"); !errors.Is(err, core.ErrConfigNotFound) {
+		t.Fatalf("重复 UnregisterConfig = %v, want ErrConfigNotFound", err)
+	}
+}
+
+func TestPrefixWatcherSpanRecordsPanic(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem, &Config{Tracer: tracing.New(mem, "")})
+	m.AddPrefixWatcher("/app/", func(string, core.EventType) {
+		panic("callback panic")
+	})
+	m.notifyPrefixWatchers(&core.ConfigChange{Key: "/app/a", EventType: core.EventTypePut})
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "etcdtrigger.callback" {
+		t.Fatalf("结束的 Span = %v, want 一个 etcdtrigger.callback", spans)
+	}
+	if status := spans[0].Status(); status.Code != codes.Error {
+		t.Fatalf("回调 panic 时 Span 状态 = %v, want Error", status)
+	}
+}
+
+func TestPrefixWatcherUnsubscribe(t *testing.T) {
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem, &Config{
+		Configs: []core.WatchConfig{{Path: "/app/", Struct: &serverConfig{}}},
+	})
+
+	keys := make(chan string, 10)
+	sub := m.AddPrefixWatcher("/app/", func(key string, _ core.EventType) {
+		keys <- key
+	})
+	put(t, mem, "/app/a", `{"host":"a","port":1}`)
+	select {
+	case <-keys:
+	case <-time.After(testutil.Timeout):
+		t.Fatal("取消订阅前未收到回调")
+	}
+
+	sub.Unsubscribe()
+	select {
+	case <-sub.Done():
+	case <-time.After(testutil.Timeout):
+		t.Fatal("取消订阅后订阅未结束")
+	}
+	if !errors.Is(sub.Err(), core.ErrWatchCanceled) {
+		t.Fatalf("Err = %v, want ErrWatchCanceled", sub.Err())
+	}
+	m.prefixWatchers.Range(func(id, _ any) bool {
+		t.Fatalf("取消订阅后 prefixWatchers 仍包含监听器 %v", id)
+		return false
+	})
+
+	put(t, mem, "/app/b", `{"host":"b","port":1}`)
+	testutil.Eventually(t, func() bool { return m.GetConfig("/app/b", &serverConfig{}) }, "配置未加载")
+	select {
+	case key := <-keys:
+		t.Fatalf("取消订阅后收到回调 %s", key)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSameStructOnTwoPaths(t *testing.T) {
+	mem := backend.NewMemory()
+	put(t, mem, "/primary/db", `{"host":"p","port":1}`)
+	put(t, mem, "/replica/db", `{"host":"r","port":2}`)
+	m := newTestManager(t, mem, &Config{
+		Configs: []core.WatchConfig{
+			{Path: "/primary/", Struct: &serverConfig{}},
+			{Path: "/replica/", Struct: &serverConfig{}},
+		},
+	})
+
+	for key, want := range map[string]serverConfig{
+		"/primary/db": {Host: "p", Port: 1},
+		"/replica/db": {Host: "r", Port: 2},
+	} {
+		var got serverConfig
+		if !m.GetConfig(key, &got) || got != want {
+			t.Fatalf("GetConfig(%s) = %+v, want %+v", key, got, want)
+		}
+	}
+	if keys := m.GetAllKeys("/primary/"); len(keys) != 1 || keys[0] != "/primary/db" {
+		t.Fatalf("GetAllKeys(/primary/) = %v", keys)
+	}
+	if keys := m.GetAllKeys("/replica/"); len(keys) != 1 || keys[0] != "/replica/db" {
+		t.Fatalf("GetAllKeys(/replica/) = %v", keys)
+	}
+
+	replica := changes(m, "/replica/")
+	nextChange(t, replica) // 添加监听器时回放
+
+	// 注销一个路径不影响同类型的另一个路径
+	if err := m.UnregisterConfig("/primary/"); err != nil {
+		t.Fatalf("UnregisterConfig: %v", err)
+	}
+	if keys := m.GetAllKeys("/primary/"); len(keys) != 0 {
+		t.Fatalf("注销后 /primary/ 仍有键 %v", keys)
+	}
+	var got serverConfig
+	if !m.GetConfig("/replica/db", &got) || got.Host != "r" {
+		t.Fatalf("注销 /primary/ 后 GetConfig(/replica/db) = %+v", got)
+	}
+
+	put(t, mem, "/replica/db", `{"host":"r2","port":2}`)
+	if change := nextChange(t, replica); change.Key != "/replica/db" || change.New.(*serverConfig).Host != "r2" {
+		t.Fatalf("变更 = %s %v, want /replica/db r2", change.Key, change.New)
+	}
+	testutil.Eventually(t, func() bool {
+		return m.GetConfig("/replica/db", &got) && got.Host == "r2"
+	}, "注销 /primary/ 后 /replica/ 的缓存未更新")
+}
+
+func TestPrefixWatcherPanicIsolated(t *testing.T) {
+	mem := backend.NewMemory()
+	panics := make(chan error, 8)
+	m := newTestManager(t, mem, &Config{
+		Configs: []core.WatchConfig{{Path: "/app/", Struct: &serverConfig{}}},
+		OnError: func(_ string, err error) { panics <- err },
+	})
+
+	// 第一个监听器在 /app/a 上 panic，之后的变更与另一个监听器不受影响
+	first := make(chan string, 8)
+	second := make(chan string, 8)
+	m.AddPrefixWatcher("/app/", func(key string, _ core.EventType) {
+		if key == "/app/a" {
+			panic("callback panic")
+		}
+		first <- key
+	})
+	m.AddPrefixWatcher("/app/", func(key string, _ core.EventType) {
+		second <- key
+	})
+
+	put(t, mem, "/app/a", `{"host":"a","port":1}`)
+	put(t, mem, "/app/b", `{"host":"b","port":1}`)
+
+	receive := func(ch <-chan string, want string) {
+		t.Helper()
+		select {
+		case key := <-ch:
+			if key != want {
+				t.Fatalf("收到 %s, want %s", key, want)
+			}
+		case <-time.After(testutil.Timeout):
+			t.Fatalf("未收到 %s", want)
+		}
+	}
+	receive(first, "/app/b")
+	receive(second, "/app/a")
+	receive(second, "/app/b")
+
+	select {
+	case err := <-panics:
+		var panicErr *core.PanicError
+		if !errors.As(err, &panicErr) || panicErr.Key != "/app/a" || panicErr.Value != "callback panic" || len(panicErr.Stack) == 0 {
+			t.Fatalf("OnError = %v, want /app/a 的 PanicError 且包含调用栈", err)
+		}
+	case <-time.After(testutil.Timeout):
+		t.Fatal("OnError 未收到 panic")
+	}
+}
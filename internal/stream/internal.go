@@ -0,0 +1,362 @@
+package stream
+
+import (
+	"cmp"
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/snapshot"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	minBackoff    = 100 * time.Millisecond // 首次重连等待
+	maxBackoff    = 30 * time.Second       // 最长重连等待
+	snapshotDelay = time.Second            // 监听事件后合并写入快照的最长延迟
+)
+
+// watch 执行一次监听
+// 返回：
+//   - compacted: 监听版本已被压缩，需要重新同步
+//   - established: 监听是否成功建立过，用于重置退避
+//
+// 说明：
+//   - 后端在连接中断、失去 leader 等情况下关闭通道，由 Run 负责重连
+//   - 监听事件的快照在 snapshotDelay 内合并为一次写入，未写入的变更在监听结束时写入
+func (s *Stream) watch(ctx context.Context) (compacted, established bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req := core.WatchRequest{
+		Prefix:   true,
+		Revision: s.revision + 1,
+		PrevKV:   s.config.PrevKV,
+	}
+
+	var flush *time.Timer
+	defer func() {
+		if flush != nil {
+			flush.Stop()
+		}
+	}()
+
+	responses := s.backend.Watch(ctx, s.config.Prefix, req)
+	for {
+		var flushC <-chan time.Time
+		if flush != nil {
+			flushC = flush.C
+		}
+
+		var watchResp *core.WatchResponse
+		select {
+		case resp, ok := <-responses:
+			if !ok {
+				return false, established
+			}
+			watchResp = resp
+		case <-flushC:
+			flush = nil
+			s.saveSnapshot()
+			continue
+		}
+
+		if watchResp.CompactRevision != 0 {
+			return true, established
+		}
+
+		if watchResp.Err != nil {
+			s.log("watch").WithFields(core.Field("prefix", s.config.Prefix), core.Field("error", watchResp.Err.Error())).Error("监听错误")
+			continue
+		}
+
+		if watchResp.Created {
+			established = true
+			s.setState(core.WatchStateWatching, nil)
+			if s.stale.Load() {
+				// 监听追上当前版本后才会收到进度通知，据此清除陈旧标记
+				if err := s.backend.RequestProgress(ctx); err != nil {
+					s.log("watch").WithFields(core.Field("prefix", s.config.Prefix), core.Field("error", err.Error())).Error("请求监听进度失败")
+				}
+			}
+			continue
+		}
+
+		if watchResp.Progress {
+			s.markCurrent()
+			s.config.Metrics.RevisionLag(s.config.Prefix, 0)
+			continue
+		}
+
+		if len(watchResp.Events) == 0 {
+			continue
+		}
+
+		// 同一版本的事件（同一事务）全部分发前，低水位不越过该版本
+		first := watchResp.Events[0].Kv.ModRevision
+		links, err := s.config.Tracer.Lookup(ctx, watchResp.Events)
+		if err != nil {
+			s.log("trace").WithFields(core.Field("prefix", s.config.Prefix), core.Field("revision", watchResp.Revision), core.Field("error", err.Error())).Error("读取链路旁路键失败，相关事件不关联写入方链路")
+		}
+		s.hold(first)
+		for _, ev := range watchResp.Events {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := s.apply(ctx, ev, links); err != nil {
+				s.fail(ev.Kv.Key, ev.Kv.ModRevision)
+			}
+		}
+		s.release(ctx, first)
+		if ctx.Err() != nil {
+			// 批次未分发完整，当前版本不能作为续接点，保留磁盘上上一个完整批次的快照
+			s.dirty = false
+			return false, established
+		}
+		s.commit(ctx, s.revision)
+		if flush == nil && s.config.Snapshot != nil {
+			flush = time.NewTimer(snapshotDelay)
+		}
+		s.config.Metrics.RevisionLag(s.config.Prefix, watchResp.Revision-s.revision)
+	}
+}
+
+// apply 处理单个监听事件并更新已知键集合
+// 参数：
+//   - links: 本批事件的旁路键，未启用链路追踪时为 nil
+//
+// 返回：
+//   - error: handler 返回的错误
+func (s *Stream) apply(ctx context.Context, ev *core.KvEvent, links tracing.Links) error {
+	key := ev.Kv.Key
+	event := &core.WatchEvent{
+		Key:         key,
+		EventType:   ev.Type,
+		Revision:    ev.Kv.ModRevision,
+		ModRevision: ev.Kv.ModRevision,
+	}
+
+	switch ev.Type {
+	case core.EventTypePut:
+		event.Value = ev.Kv.Value
+		event.CreateRevision = ev.Kv.CreateRevision
+		event.Version = ev.Kv.Version
+		event.Lease = ev.Kv.Lease
+		s.known[key] = ev.Kv.ModRevision
+		if s.config.Snapshot != nil {
+			s.entries[key] = &snapshot.Entry{
+				Key:            key,
+				Value:          ev.Kv.Value,
+				CreateRevision: ev.Kv.CreateRevision,
+				ModRevision:    ev.Kv.ModRevision,
+				Version:        ev.Kv.Version,
+				Lease:          ev.Kv.Lease,
+			}
+		}
+	case core.EventTypeDelete:
+		delete(s.known, key)
+		delete(s.entries, key)
+	}
+	s.dirty = true
+
+	if ev.PrevKv != nil {
+		event.PrevValue = ev.PrevKv.Value
+	}
+
+	s.revision = ev.Kv.ModRevision
+	s.config.Metrics.Event(s.config.Prefix, ev.Type)
+	return s.dispatch(ctx, event, links)
+}
+
+// dispatch 将事件交给 handler
+// 参数：
+//   - links: 关联写入方链路的旁路键，仅监听事件关联，快照与同步事件为 nil
+//
+// 说明：
+//   - Async 时先登记到低水位，handler 返回错误（事件未被接收）时立即确认
+func (s *Stream) dispatch(ctx context.Context, event *core.WatchEvent, links tracing.Links) error {
+	if s.config.Async {
+		s.tracker.add(event.Revision)
+	}
+
+	var err error
+	if links != nil {
+		err = s.handle(ctx, event, links)
+	} else {
+		err = s.handler(event)
+	}
+	if err != nil && s.config.Async {
+		s.Done(ctx, event, err)
+	}
+	return err
+}
+
+// hold 在分发同一版本的一批事件前占位，防止低水位提前越过该版本
+func (s *Stream) hold(revision int64) {
+	if s.config.Async {
+		s.tracker.add(revision)
+	}
+}
+
+// release 释放 hold 的占位
+func (s *Stream) release(ctx context.Context, revision int64) {
+	if s.config.Async {
+		s.complete(ctx, revision, "", 0)
+	}
+}
+
+// complete 登记一个事件完成并按低水位推进检查点，仅 Async 时使用
+// 参数：
+//   - key: 事件的键
+//   - failed: 重新回放该键需要的最早版本，0 表示处理成功
+func (s *Stream) complete(ctx context.Context, revision int64, key string, failed int64) {
+	s.tracker.mu.Lock()
+	defer s.tracker.mu.Unlock()
+
+	if failed > 0 {
+		s.tracker.fail(key, failed)
+	}
+	if low := s.tracker.advance(s.tracker.done(revision)); low > 0 {
+		s.saveCheckpoint(ctx, low)
+	}
+}
+
+// fail 登记处理失败的键，仅非 Async 时使用，Async 时由 Done 登记
+func (s *Stream) fail(key string, revision int64) {
+	if s.config.Async {
+		return
+	}
+
+	s.tracker.mu.Lock()
+	defer s.tracker.mu.Unlock()
+	s.tracker.fail(key, revision)
+}
+
+// forgetFailed 全量同步前清除处理失败的记录
+// 说明：
+//   - 失败的键从已知键集合中移除，同步时以 PUT 事件重新送达其当前值
+func (s *Stream) forgetFailed() {
+	s.tracker.mu.Lock()
+	defer s.tracker.mu.Unlock()
+
+	for key := range s.tracker.failed {
+		delete(s.known, key)
+	}
+	clear(s.tracker.failed)
+}
+
+// handle 在写入方链路的子 Span 中处理监听事件
+// 说明：
+//   - Span 的上下文不随监听重连取消，回调可通过 event.Context() 继续传播
+func (s *Stream) handle(ctx context.Context, event *core.WatchEvent, links tracing.Links) error {
+	parent := context.WithoutCancel(links.Extract(ctx, event))
+	spanCtx, span := s.config.Tracer.Start(parent, "etcdtrigger.receive", trace.SpanKindConsumer, event.Key)
+	err := s.handler(event.WithContext(spanCtx))
+	tracing.End(span, err)
+	return err
+}
+
+// commit 一批事件处理完成后推进检查点，仅非 Async 时使用
+// 参数：
+//   - revision: 本批事件的最高版本
+//
+// 说明：
+//   - 同一版本的事件在同一批中送达，整批处理完成后才保存，检查点不会停在一个事务的中间
+//   - 检查点不越过处理失败的版本，失败清除前重启会从该版本重新回放
+func (s *Stream) commit(ctx context.Context, revision int64) {
+	if s.config.Async {
+		return
+	}
+
+	s.tracker.mu.Lock()
+	defer s.tracker.mu.Unlock()
+
+	if revision = s.tracker.advance(revision); revision > 0 {
+		s.saveCheckpoint(ctx, revision)
+	}
+}
+
+// saveCheckpoint 保存检查点，失败时仅记录日志，下次成功处理时会再次推进
+func (s *Stream) saveCheckpoint(ctx context.Context, revision int64) {
+	if s.config.Checkpoint == nil {
+		return
+	}
+
+	if err := s.config.Checkpoint.Save(ctx, s.checkpointName(), revision); err != nil {
+		s.log("checkpoint").WithFields(core.Field("prefix", s.config.Prefix), core.Field("revision", revision), core.Field("error", err.Error())).Error("保存检查点失败")
+	}
+}
+
+// checkpointName 返回检查点名称
+func (s *Stream) checkpointName() string {
+	return cmp.Or(s.config.CheckpointName, s.config.Prefix)
+}
+
+// saveSnapshot 持久化有变更的快照，失败时仅记录日志，下次变更时会再次写入
+func (s *Stream) saveSnapshot() {
+	if s.config.Snapshot == nil || !s.dirty {
+		return
+	}
+
+	entries := make([]*snapshot.Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+
+	err := s.config.Snapshot.Save(&snapshot.Snapshot{
+		Prefix:   s.config.Prefix,
+		Revision: s.revision,
+		Entries:  entries,
+	})
+	if err != nil {
+		s.log("snapshot").WithFields(core.Field("prefix", s.config.Prefix), core.Field("revision", s.revision), core.Field("error", err.Error())).Error("保存快照失败")
+		return
+	}
+	s.dirty = false
+}
+
+// markCurrent 清除陈旧标记
+func (s *Stream) markCurrent() {
+	if s.stale.CompareAndSwap(true, false) {
+		s.log("restore").WithFields(core.Field("prefix", s.config.Prefix), core.Field("revision", s.revision)).Info("快照已与 etcd 对齐")
+	}
+}
+
+// setState 更新状态并通知回调，状态未变化时不通知
+func (s *Stream) setState(state core.WatchState, err error) {
+	if s.state == state {
+		return
+	}
+	s.state = state
+
+	fields := []core.LogField{core.Field("prefix", s.config.Prefix), core.Field("state", state)}
+	if err != nil {
+		fields = append(fields, core.Field("error", err.Error()))
+	}
+	s.log("state").WithFields(fields...).Info("监听状态变化")
+
+	if s.config.OnState != nil {
+		s.config.OnState(s.config.Prefix, state, err)
+	}
+}
+
+// backoffDelay 计算带抖动的指数退避时长
+// 说明：
+//   - 基准时长从 minBackoff 开始逐次翻倍，不超过 maxBackoff
+//   - 实际等待在基准时长的 [1/2, 1] 区间内随机
+func backoffDelay(attempt int) time.Duration {
+	delay := maxBackoff
+	if attempt < 16 {
+		delay = min(minBackoff<<attempt, maxBackoff)
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// log 创建结构化日志
+func (s *Stream) log(operation string) *core.LogEntry {
+	return s.logCtx.WithModule("stream", operation)
+}
@@ -0,0 +1,367 @@
+// Package stream 提供前缀快照与监听的统一实现。
+//
+// Stream 维护前缀下已知的键集合与最后处理的版本：
+//   - Init: 存在检查点时从检查点版本续接，否则执行 Sync
+//   - Restore: 从本地快照恢复已知键与版本，标记为陈旧，由 Run 从快照版本续接
+//   - Sync: 全量获取前缀，与已知键集合对比后补发 PUT 与合成的 DELETE 事件
+//   - Run: 从最后处理版本的下一个版本开始监听，遇到压缩时自动 Sync 后续接，
+//     监听通道关闭时以带抖动的指数退避重连
+//
+// Watcher 与 Store 共用此实现，保证快照与事件流和 etcd 完全一致。
+package stream
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/metrics"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/snapshot"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/tracing"
+)
+
+// Handler 事件处理函数
+// 返回 nil 表示事件已成功处理，启用检查点时据此推进检查点
+type Handler func(event *core.WatchEvent) error
+
+// Config 监听流配置
+type Config struct {
+	Prefix         string                  // 监听前缀
+	OnState        core.WatchStateCallback // 状态变化回调（可为 nil）
+	Checkpoint     core.CheckpointStore    // 检查点存储（可为 nil）
+	CheckpointName string                  // 检查点名称，为空时为 Prefix
+	PrevKV         bool                    // 监听事件是否携带变更前的值
+	OnSync         func()                  // 每次全量同步成功或从快照恢复后回调（可为 nil）
+	Snapshot       *snapshot.Dir           // 本地快照目录（可为 nil），同步后立即持久化，监听事件合并后延迟持久化
+	Metrics        *metrics.Metrics        // 指标记录器（可为 nil）
+	Tracer         *tracing.Tracer         // 链路追踪（可为 nil），监听事件在写入方链路的子 Span 中处理
+	Async          bool                    // 事件由 handler 异步处理，处理完成后必须调用 Stream.Done
+}
+
+// Stream 前缀监听流
+// 说明：
+//   - 非并发安全，Sync 与 Run 需在同一协程中顺序调用
+type Stream struct {
+	backend  core.Backend
+	logCtx   *core.LogContext
+	config   *Config
+	handler  Handler
+	known    map[string]int64           // 已知键 -> ModRevision
+	entries  map[string]*snapshot.Entry // 已知键 -> 快照条目，仅启用快照时维护
+	dirty    bool                       // 快照条目是否有未持久化的变更
+	revision int64                      // 最后处理的版本
+	synced   bool                       // 是否完成过全量同步
+	stale    atomic.Bool                // 是否为尚未与 etcd 对齐的快照数据
+	state    core.WatchState            // 当前状态
+	tracker  *tracker                   // 检查点进度
+}
+
+// New 创建监听流
+// 参数：
+//   - backend: 存储后端
+//   - logCtx: 日志上下文
+//   - config: 监听流配置
+//   - handler: 事件处理函数
+func New(backend core.Backend, logCtx *core.LogContext, config *Config, handler Handler) *Stream {
+	return &Stream{
+		backend: backend,
+		logCtx:  logCtx,
+		config:  config,
+		handler: handler,
+		known:   make(map[string]int64),
+		entries: make(map[string]*snapshot.Entry),
+		tracker: newTracker(),
+	}
+}
+
+// Revision 返回最后处理的版本
+func (s *Stream) Revision() int64 {
+	return s.revision
+}
+
+// Stale 是否仍在使用尚未与 etcd 对齐的快照数据
+// 说明：
+//   - 可并发调用
+func (s *Stream) Stale() bool {
+	return s.stale.Load()
+}
+
+// Restore 从本地快照恢复
+// 返回：
+//   - bool: 是否存在并恢复了快照
+//   - error: 快照读取失败或已损坏时返回错误
+//
+// 说明：
+//   - 快照中的值以 PUT 事件回放，事件 Revision 为快照版本
+//   - 恢复后标记为陈旧，Run 从快照版本的下一个版本续接监听，
+//     追上 etcd 当前版本或重新同步后清除陈旧标记
+func (s *Stream) Restore() (bool, error) {
+	if s.config.Snapshot == nil {
+		return false, nil
+	}
+
+	snap, err := s.config.Snapshot.Load(s.config.Prefix)
+	if err != nil || snap == nil {
+		return false, err
+	}
+
+	s.hold(snap.Revision)
+	for _, entry := range snap.Entries {
+		_ = s.dispatch(context.Background(), &core.WatchEvent{
+			Key:            entry.Key,
+			Value:          entry.Value,
+			EventType:      core.EventTypePut,
+			Revision:       snap.Revision,
+			CreateRevision: entry.CreateRevision,
+			ModRevision:    entry.ModRevision,
+			Version:        entry.Version,
+			Lease:          entry.Lease,
+		}, nil)
+		s.known[entry.Key] = entry.ModRevision
+		s.entries[entry.Key] = entry
+	}
+	s.release(context.Background(), snap.Revision)
+
+	s.revision = snap.Revision
+	s.synced = true
+	s.stale.Store(true)
+	s.log("restore").WithFields(core.Field("prefix", s.config.Prefix), core.Field("revision", snap.Revision), core.Field("count", len(snap.Entries))).Info("从快照恢复，等待与 etcd 对齐")
+
+	if s.config.OnSync != nil {
+		s.config.OnSync()
+	}
+	return true, nil
+}
+
+// Done 确认异步处理的事件已完成
+// 参数：
+//   - event: 交给 handler 的事件
+//   - err: 处理结果，被跳过的事件传 nil
+//
+// 说明：
+//   - 仅 Async 时使用，每个交给 handler 且 handler 返回 nil 的事件必须确认一次
+//   - 低水位前进时将检查点推进到低水位，但不越过处理失败的版本
+//   - 可并发调用
+func (s *Stream) Done(ctx context.Context, event *core.WatchEvent, err error) {
+	var failed int64
+	if err != nil {
+		// 从键最后修改的版本回放可以再次送达该值
+		failed = cmp.Or(event.ModRevision, event.Revision)
+	}
+	s.complete(ctx, event.Revision, event.Key, failed)
+}
+
+// Resolve 清除键的处理失败
+// 参数：
+//   - key: 失败事件的键
+//
+// 说明：
+//   - 失败的事件已在监听流之外处理（如写入死信）后调用，检查点不再停在该键的失败版本之前
+//   - 重新全量同步时会再次送达失败的键并清除所有失败，无需调用
+//   - 可并发调用
+func (s *Stream) Resolve(ctx context.Context, key string) {
+	s.tracker.mu.Lock()
+	defer s.tracker.mu.Unlock()
+
+	if !s.tracker.resolve(key) {
+		return
+	}
+	if revision := s.tracker.advance(0); revision > 0 {
+		s.saveCheckpoint(ctx, revision)
+	}
+}
+
+// Init 初始化监听流
+// 说明：
+//   - 配置了检查点且检查点存在时，从检查点版本续接，由 Run 回放期间的历史变更
+//   - 否则执行 Sync 回放当前所有值
+func (s *Stream) Init(ctx context.Context) error {
+	if s.config.Checkpoint != nil {
+		revision, err := s.config.Checkpoint.Load(ctx, s.checkpointName())
+		if err != nil {
+			if errors.Is(err, core.ErrCheckpointLoadFailed) {
+				return err
+			}
+			return fmt.Errorf("%w: %v", core.ErrCheckpointLoadFailed, err)
+		}
+
+		if revision > 0 {
+			s.tracker.saved = revision
+			s.tracker.completed = revision
+			s.revision = revision
+			s.synced = true
+			s.log("init").WithFields(core.Field("prefix", s.config.Prefix), core.Field("revision", revision)).Info("从检查点续接")
+			return nil
+		}
+	}
+
+	return s.Sync(ctx)
+}
+
+// Sync 全量同步前缀
+// 说明：
+//   - 新增或版本变化的键触发 PUT 事件
+//   - 已知但不再存在的键触发合成的 DELETE 事件
+//   - 首次同步时已知键集合为空，等价于回放当前所有值
+//   - 此前处理失败的键重新送达，失败记录随之清除
+//   - 非 Async 时所有事件处理成功后将检查点推进到快照版本
+func (s *Stream) Sync(ctx context.Context) error {
+	s.setState(core.WatchStateSyncing, nil)
+
+	resp, err := s.backend.Get(ctx, s.config.Prefix, true)
+	if err != nil {
+		return fmt.Errorf("%w: %v", core.ErrGetFailed, err)
+	}
+
+	s.forgetFailed()
+	s.hold(resp.Revision)
+	defer s.release(ctx, resp.Revision)
+
+	current := make(map[string]int64, len(resp.Kvs))
+	entries := make(map[string]*snapshot.Entry, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		key := kv.Key
+		current[key] = kv.ModRevision
+		if s.config.Snapshot != nil {
+			entries[key] = &snapshot.Entry{
+				Key:            key,
+				Value:          kv.Value,
+				CreateRevision: kv.CreateRevision,
+				ModRevision:    kv.ModRevision,
+				Version:        kv.Version,
+				Lease:          kv.Lease,
+			}
+		}
+		if s.known[key] == kv.ModRevision {
+			continue
+		}
+
+		s.config.Metrics.Event(s.config.Prefix, core.EventTypePut)
+		err := s.dispatch(ctx, &core.WatchEvent{
+			Key:            key,
+			Value:          kv.Value,
+			EventType:      core.EventTypePut,
+			Revision:       resp.Revision,
+			CreateRevision: kv.CreateRevision,
+			ModRevision:    kv.ModRevision,
+			Version:        kv.Version,
+			Lease:          kv.Lease,
+		}, nil)
+		if err != nil {
+			// 从键最后修改的版本回放可以再次送达该值
+			s.fail(key, kv.ModRevision)
+		}
+	}
+
+	for key := range s.known {
+		if _, ok := current[key]; ok {
+			continue
+		}
+
+		s.config.Metrics.Event(s.config.Prefix, core.EventTypeDelete)
+		err := s.dispatch(ctx, &core.WatchEvent{
+			Key:       key,
+			EventType: core.EventTypeDelete,
+			Revision:  resp.Revision,
+		}, nil)
+		if err != nil {
+			// 删除发生在已知版本之后
+			s.fail(key, s.known[key]+1)
+		}
+	}
+
+	s.known = current
+	s.entries = entries
+	s.revision = resp.Revision
+	s.synced = true
+	s.dirty = true
+	s.saveSnapshot()
+	s.markCurrent()
+	s.config.Metrics.RevisionLag(s.config.Prefix, 0)
+
+	s.commit(ctx, s.revision)
+
+	if s.config.OnSync != nil {
+		s.config.OnSync()
+	}
+	return nil
+}
+
+// Run 监听前缀变更直到 ctx 取消
+// 返回：
+//   - error: 总是返回 ctx.Err()
+//
+// 说明：
+//   - 尚未初始化时先执行 Sync，失败时退避重试
+//   - 从最后处理版本的下一个版本开始监听
+//   - 监听版本被压缩时立即重新 Sync，并从新的版本继续监听
+//   - 监听通道关闭（连接中断、失去 leader、客户端重建等）时退避后重连
+func (s *Stream) Run(ctx context.Context) error {
+	defer func() {
+		s.saveSnapshot()
+		s.setState(core.WatchStateStopped, ctx.Err())
+	}()
+
+	var attempt int
+	for {
+		if !s.synced {
+			if err := s.Sync(ctx); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				s.log("sync").WithFields(core.Field("prefix", s.config.Prefix), core.Field("error", err.Error())).Error("同步失败")
+				if !s.backoff(ctx, attempt, err) {
+					return ctx.Err()
+				}
+				attempt++
+				continue
+			}
+		}
+
+		compacted, established := s.watch(ctx)
+		s.saveSnapshot()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if established {
+			attempt = 0
+		}
+
+		if compacted {
+			s.log("resync").WithFields(core.Field("prefix", s.config.Prefix), core.Field("revision", s.revision)).Info("监听版本已被压缩，重新同步")
+			s.config.Metrics.Compaction(s.config.Prefix)
+			s.synced = false
+			continue
+		}
+
+		s.log("watch").WithFields(core.Field("prefix", s.config.Prefix), core.Field("revision", s.revision)).Error("监听通道关闭，准备重连")
+		if !s.backoff(ctx, attempt, core.ErrWatchFailed) {
+			return ctx.Err()
+		}
+		s.config.Metrics.Reconnect(s.config.Prefix)
+		attempt++
+	}
+}
+
+// backoff 进入退避状态并等待
+// 返回：
+//   - bool: ctx 取消时为 false
+func (s *Stream) backoff(ctx context.Context, attempt int, err error) bool {
+	s.setState(core.WatchStateBackoff, err)
+
+	timer := time.NewTimer(backoffDelay(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
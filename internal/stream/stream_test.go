@@ -0,0 +1,543 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/snapshot"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/testutil"
+)
+
+// recorder 记录 handler 收到的事件
+type recorder struct {
+	events chan *core.WatchEvent
+}
+
+func newRecorder() *recorder {
+	return &recorder{events: make(chan *core.WatchEvent, 100)}
+}
+
+func (r *recorder) handle(event *core.WatchEvent) error {
+	r.events <- event
+	return nil
+}
+
+// expect 按顺序等待指定的事件，want 为 "类型 键"
+func (r *recorder) expect(t *testing.T, want ...string) []*core.WatchEvent {
+	t.Helper()
+
+	got := make([]*core.WatchEvent, 0, len(want))
+	for _, w := range want {
+		select {
+		case event := <-r.events:
+			if desc := string(event.EventType) + " " + event.Key; desc != w {
+				t.Fatalf("事件 = %s, want %s", desc, w)
+			}
+			got = append(got, event)
+		case <-time.After(testutil.Timeout):
+			t.Fatalf("未收到事件 %s", w)
+		}
+	}
+	return got
+}
+
+// expectNone 确认没有多余的事件
+func (r *recorder) expectNone(t *testing.T) {
+	t.Helper()
+
+	select {
+	case event := <-r.events:
+		t.Fatalf("多余的事件 %s %s", event.EventType, event.Key)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// states 记录状态变化
+type states struct {
+	mu   sync.Mutex
+	seen []core.WatchState
+}
+
+func (s *states) record(_ string, state core.WatchState, _ error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen = append(s.seen, state)
+}
+
+// count 返回进入 state 的次数
+func (s *states) count(state core.WatchState) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for _, current := range s.seen {
+		if current == state {
+			n++
+		}
+	}
+	return n
+}
+
+// mapCheckpoint 内存检查点存储
+type mapCheckpoint struct {
+	mu        sync.Mutex
+	revisions map[string]int64
+}
+
+func newMapCheckpoint() *mapCheckpoint {
+	return &mapCheckpoint{revisions: make(map[string]int64)}
+}
+
+func (c *mapCheckpoint) Load(_ context.Context, name string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.revisions[name], nil
+}
+
+func (c *mapCheckpoint) Save(_ context.Context, name string, revision int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.revisions[name] = revision
+	return nil
+}
+
+// put 直接写入内存后端
+func put(t *testing.T, mem *backend.Memory, key, value string) int64 {
+	t.Helper()
+
+	revision, err := mem.Put(context.Background(), key, []byte(value), 0)
+	if err != nil {
+		t.Fatalf("Put %s: %v", key, err)
+	}
+	return revision
+}
+
+// del 直接从内存后端删除
+func del(t *testing.T, mem *backend.Memory, key string) {
+	t.Helper()
+
+	if _, err := mem.Delete(context.Background(), key, false); err != nil {
+		t.Fatalf("Delete %s: %v", key, err)
+	}
+}
+
+// run 在后台运行监听流，返回停止函数
+func run(st *Stream) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = st.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestStreamSync(t *testing.T) {
+	mem := backend.NewMemory()
+	rec := newRecorder()
+	st := New(mem, &core.LogContext{}, &Config{Prefix: "/p/"}, rec.handle)
+
+	put(t, mem, "/p/a", "1")
+	put(t, mem, "/p/b", "1")
+	put(t, mem, "/other", "1")
+
+	if err := st.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	events := rec.expect(t, "PUT /p/a", "PUT /p/b")
+	if events[0].Revision != mem.Revision() || events[0].ModRevision != 2 {
+		t.Fatalf("Revision = %d, ModRevision = %d, want %d, 2", events[0].Revision, events[0].ModRevision, mem.Revision())
+	}
+
+	// 再次同步只补发变化：修改、新增与合成的删除
+	del(t, mem, "/p/a")
+	put(t, mem, "/p/b", "2")
+	put(t, mem, "/p/c", "1")
+
+	if err := st.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	events = rec.expect(t, "PUT /p/b", "PUT /p/c", "DELETE /p/a")
+	if deleted := events[2]; deleted.ModRevision != 0 || deleted.Revision != mem.Revision() {
+		t.Fatalf("合成的 DELETE = %+v, want 只有 Key 与 Revision", deleted)
+	}
+	rec.expectNone(t)
+
+	if st.Revision() != mem.Revision() {
+		t.Fatalf("Revision = %d, want %d", st.Revision(), mem.Revision())
+	}
+}
+
+func TestStreamResyncAfterCompaction(t *testing.T) {
+	mem := backend.NewMemory()
+	rec := newRecorder()
+	st := New(mem, &core.LogContext{}, &Config{Prefix: "/p/"}, rec.handle)
+
+	put(t, mem, "/p/a", "1")
+	put(t, mem, "/p/b", "1")
+	if err := st.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	rec.expect(t, "PUT /p/a", "PUT /p/b")
+
+	// 监听开始前期间的历史已被压缩
+	del(t, mem, "/p/a")
+	put(t, mem, "/p/c", "1")
+	if err := mem.Compact(mem.Revision()); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+
+	stop := run(st)
+	defer stop()
+
+	events := rec.expect(t, "PUT /p/c", "DELETE /p/a")
+	if events[1].ModRevision != 0 {
+		t.Fatalf("重新同步的 DELETE 携带了 ModRevision %d", events[1].ModRevision)
+	}
+
+	// 重新同步后从新的版本继续监听
+	put(t, mem, "/p/d", "1")
+	rec.expect(t, "PUT /p/d")
+	rec.expectNone(t)
+}
+
+func TestStreamReconnect(t *testing.T) {
+	mem := backend.NewMemory()
+	rec := newRecorder()
+	st := &states{}
+	stream := New(mem, &core.LogContext{}, &Config{Prefix: "/p/", OnState: st.record}, rec.handle)
+
+	stop := run(stream)
+	defer stop()
+	testutil.Eventually(t, func() bool { return st.count(core.WatchStateWatching) == 1 }, "监听未建立")
+
+	mem.DropWatches()
+	// 中断期间的写入在重连后从断开的版本补发
+	put(t, mem, "/p/a", "1")
+
+	rec.expect(t, "PUT /p/a")
+	testutil.Eventually(t, func() bool { return st.count(core.WatchStateWatching) == 2 }, "监听未重连")
+	if st.count(core.WatchStateBackoff) != 1 {
+		t.Fatalf("进入 BACKOFF %d 次, want 1", st.count(core.WatchStateBackoff))
+	}
+
+	put(t, mem, "/p/b", "1")
+	rec.expect(t, "PUT /p/b")
+	rec.expectNone(t)
+}
+
+func TestStreamCheckpointResume(t *testing.T) {
+	mem := backend.NewMemory()
+	checkpoints := newMapCheckpoint()
+	config := &Config{Prefix: "/p/", Checkpoint: checkpoints}
+
+	put(t, mem, "/p/a", "1")
+
+	first := newRecorder()
+	st := New(mem, &core.LogContext{}, config, first.handle)
+	if err := st.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	first.expect(t, "PUT /p/a")
+
+	stop := run(st)
+	b := put(t, mem, "/p/b", "1")
+	first.expect(t, "PUT /p/b")
+	testutil.Eventually(t, func() bool {
+		revision, _ := checkpoints.Load(context.Background(), "/p/")
+		return revision == b
+	}, "检查点未推进")
+	stop()
+
+	// 停止期间的变更在续接后回放，已处理的值不再回放
+	put(t, mem, "/p/c", "1")
+	del(t, mem, "/p/a")
+
+	second := newRecorder()
+	st = New(mem, &core.LogContext{}, config, second.handle)
+	if err := st.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if st.Revision() != b {
+		t.Fatalf("续接版本 = %d, want %d", st.Revision(), b)
+	}
+	second.expectNone(t)
+
+	stop = run(st)
+	defer stop()
+	second.expect(t, "PUT /p/c", "DELETE /p/a")
+	second.expectNone(t)
+}
+
+func TestStreamCheckpointBatch(t *testing.T) {
+	mem := backend.NewMemory()
+	checkpoints := newMapCheckpoint()
+	load := func() int64 {
+		revision, _ := checkpoints.Load(context.Background(), "/p/")
+		return revision
+	}
+
+	rec := newRecorder()
+	gate := make(chan struct{})
+	handle := func(event *core.WatchEvent) error {
+		if event.Key == "/p/b" {
+			<-gate
+		}
+		if event.Key == "/p/c" {
+			return errors.New("处理失败")
+		}
+		return rec.handle(event)
+	}
+
+	st := New(mem, &core.LogContext{}, &Config{Prefix: "/p/", Checkpoint: checkpoints}, handle)
+	if err := st.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	initial := load()
+	stop := run(st)
+	defer stop()
+
+	// 同一事务的事件全部处理完成前检查点不推进到该版本
+	txn, err := mem.Txn(context.Background(), &core.Txn{Then: []core.Op{
+		{Type: core.EventTypePut, Key: "/p/a", Value: []byte("1")},
+		{Type: core.EventTypePut, Key: "/p/b", Value: []byte("1")},
+	}})
+	if err != nil {
+		t.Fatalf("Txn: %v", err)
+	}
+	rec.expect(t, "PUT /p/a")
+	time.Sleep(20 * time.Millisecond)
+	if got := load(); got != initial {
+		t.Fatalf("事务处理到一半时检查点 = %d, want %d", got, initial)
+	}
+	close(gate)
+	rec.expect(t, "PUT /p/b")
+	testutil.Eventually(t, func() bool { return load() == txn.Revision }, "事务处理完成后检查点未推进")
+
+	// 处理失败的版本之后的事件成功也不推进检查点
+	put(t, mem, "/p/c", "1")
+	put(t, mem, "/p/d", "1")
+	rec.expect(t, "PUT /p/d")
+	time.Sleep(20 * time.Millisecond)
+	if got := load(); got != txn.Revision {
+		t.Fatalf("处理失败后检查点 = %d, want %d", got, txn.Revision)
+	}
+}
+
+func TestStreamSnapshotRestore(t *testing.T) {
+	mem := backend.NewMemory()
+	dir, err := snapshot.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDir: %v", err)
+	}
+	config := &Config{Prefix: "/p/", Snapshot: dir}
+
+	put(t, mem, "/p/a", "1")
+	first := newRecorder()
+	st := New(mem, &core.LogContext{}, config, first.handle)
+	if err := st.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	first.expect(t, "PUT /p/a")
+
+	// 监听事件的快照在停止时写入
+	stop := run(st)
+	put(t, mem, "/p/b", "1")
+	first.expect(t, "PUT /p/b")
+	stop()
+
+	snap, err := dir.Load("/p/")
+	if err != nil || snap == nil {
+		t.Fatalf("Load = %v, %v", snap, err)
+	}
+	if snap.Revision != mem.Revision() || len(snap.Entries) != 2 {
+		t.Fatalf("快照版本 %d, %d 个条目, want %d, 2", snap.Revision, len(snap.Entries), mem.Revision())
+	}
+
+	// 从快照恢复后标记为陈旧，续接监听追上后清除
+	put(t, mem, "/p/c", "1")
+	rec := newRecorder()
+	st = New(mem, &core.LogContext{}, config, rec.handle)
+	restored, err := st.Restore()
+	if err != nil || !restored {
+		t.Fatalf("Restore = %v, %v", restored, err)
+	}
+	if !st.Stale() {
+		t.Fatal("从快照恢复后 Stale 为 false")
+	}
+	// 快照条目的顺序不固定
+	replayed := make(map[string]bool)
+	for range snap.Entries {
+		var event *core.WatchEvent
+		select {
+		case event = <-rec.events:
+		case <-time.After(testutil.Timeout):
+			t.Fatal("未收到回放事件")
+		}
+		if event.EventType != core.EventTypePut || event.Revision != snap.Revision {
+			t.Fatalf("回放事件 = %s (Revision %d), want PUT (Revision %d)", event.EventType, event.Revision, snap.Revision)
+		}
+		replayed[event.Key] = true
+	}
+	if !replayed["/p/a"] || !replayed["/p/b"] {
+		t.Fatalf("回放的键 = %v, want /p/a 与 /p/b", replayed)
+	}
+
+	stop = run(st)
+	defer stop()
+	rec.expect(t, "PUT /p/c")
+	testutil.Eventually(t, func() bool { return !st.Stale() }, "追上当前版本后仍为陈旧")
+}
+
+func TestTrackerLowWaterMark(t *testing.T) {
+	type step struct {
+		add  int64 // 非 0 时登记
+		done int64 // 非 0 时完成
+		want int64 // 完成后的低水位
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name:  "in order",
+			steps: []step{{add: 5}, {add: 6}, {done: 5, want: 5}, {done: 6, want: 6}},
+		},
+		{
+			name:  "out of order",
+			steps: []step{{add: 5}, {add: 6}, {add: 7}, {done: 7, want: 4}, {done: 6, want: 4}, {done: 5, want: 7}},
+		},
+		{
+			name: "same revision held",
+			steps: []step{
+				{add: 5}, // 占位
+				{add: 5}, {add: 5},
+				{done: 5, want: 4}, {done: 5, want: 4},
+				{done: 5, want: 5}, // 释放占位
+			},
+		},
+		{
+			name:  "gap below pending",
+			steps: []step{{add: 3}, {add: 9}, {done: 3, want: 8}, {done: 9, want: 9}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTracker()
+			for i, s := range tt.steps {
+				if s.add != 0 {
+					tr.add(s.add)
+					continue
+				}
+				tr.mu.Lock()
+				got := tr.done(s.done)
+				tr.mu.Unlock()
+				if got != s.want {
+					t.Fatalf("第 %d 步 done(%d) = %d, want %d", i, s.done, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamAsyncCheckpoint(t *testing.T) {
+	mem := backend.NewMemory()
+	checkpoints := newMapCheckpoint()
+	rec := newRecorder()
+	st := New(mem, &core.LogContext{}, &Config{Prefix: "/p/", Checkpoint: checkpoints, Async: true}, rec.handle)
+
+	if err := st.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	stop := run(st)
+	defer stop()
+
+	a := put(t, mem, "/p/a", "1")
+	b := put(t, mem, "/p/b", "1")
+	events := rec.expect(t, "PUT /p/a", "PUT /p/b")
+
+	load := func() int64 {
+		revision, _ := checkpoints.Load(context.Background(), "/p/")
+		return revision
+	}
+
+	// 较新的事件先完成时检查点不越过未完成的事件
+	st.Done(context.Background(), events[1], nil)
+	time.Sleep(20 * time.Millisecond)
+	if got := load(); got >= a {
+		t.Fatalf("检查点 = %d, 未完成 %d 时不应推进到该版本", got, a)
+	}
+
+	st.Done(context.Background(), events[0], nil)
+	testutil.Eventually(t, func() bool { return load() == b }, "全部完成后检查点未推进到最高版本")
+
+	// 处理失败的事件之后完成的事件不推进检查点
+	put(t, mem, "/p/c", "1")
+	put(t, mem, "/p/d", "1")
+	events = rec.expect(t, "PUT /p/c", "PUT /p/d")
+	st.Done(context.Background(), events[0], errors.New("处理失败"))
+	st.Done(context.Background(), events[1], nil)
+	time.Sleep(20 * time.Millisecond)
+	if got := load(); got != b {
+		t.Fatalf("处理失败后检查点 = %d, want %d", got, b)
+	}
+
+	// 失败在监听流之外处理后检查点恢复推进
+	st.Resolve(context.Background(), "/p/c")
+	testutil.Eventually(t, func() bool { return load() == events[1].Revision }, "失败清除后检查点未推进")
+}
+
+func TestStreamCheckpointRecovery(t *testing.T) {
+	mem := backend.NewMemory()
+	checkpoints := newMapCheckpoint()
+	load := func() int64 {
+		revision, _ := checkpoints.Load(context.Background(), "/p/")
+		return revision
+	}
+
+	rec := newRecorder()
+	var failures int
+	handle := func(event *core.WatchEvent) error {
+		if event.Key == "/p/a" && failures == 0 {
+			failures++
+			return errors.New("处理失败")
+		}
+		return rec.handle(event)
+	}
+
+	st := New(mem, &core.LogContext{}, &Config{Prefix: "/p/", Checkpoint: checkpoints}, handle)
+	if err := st.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	initial := load()
+	stop := run(st)
+
+	put(t, mem, "/p/a", "1")
+	put(t, mem, "/p/b", "1")
+	rec.expect(t, "PUT /p/b")
+	time.Sleep(20 * time.Millisecond)
+	if got := load(); got != initial {
+		t.Fatalf("处理失败后检查点 = %d, want %d", got, initial)
+	}
+	stop()
+
+	// 重新全量同步再次送达失败的键，检查点推进到同步版本
+	if err := st.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	rec.expect(t, "PUT /p/a")
+	rec.expectNone(t)
+	if got := load(); got != mem.Revision() {
+		t.Fatalf("重新同步后检查点 = %d, want %d", got, mem.Revision())
+	}
+}
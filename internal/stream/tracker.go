@@ -0,0 +1,101 @@
+package stream
+
+import "sync"
+
+// tracker 检查点进度
+// 说明：
+//   - 监听流按版本递增的顺序分发事件，低水位为所有已分发事件都已完成的最高版本，仅 Async 时使用
+//   - 同一版本的多个事件（全量同步、同一事务）以占位计数保护，分发完成前低水位不会越过该版本
+//   - 检查点不越过处理失败的版本，失败按键记录，键的失败清除后检查点恢复推进
+type tracker struct {
+	mu         sync.Mutex
+	pending    map[int64]int    // 版本 -> 未完成的事件数（含分发期间的占位）
+	dispatched int64            // 已分发的最高版本
+	completed  int64            // 已处理完成的最高版本，不受失败限制
+	saved      int64            // 已保存的检查点版本
+	failed     map[string]int64 // 处理失败的键 -> 需要重新回放的最早版本
+}
+
+// newTracker 创建低水位跟踪
+func newTracker() *tracker {
+	return &tracker{
+		pending: make(map[int64]int),
+		failed:  make(map[string]int64),
+	}
+}
+
+// add 登记一个已分发、尚未完成的事件
+func (t *tracker) add(revision int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[revision]++
+	t.dispatched = max(t.dispatched, revision)
+}
+
+// done 登记一个事件完成，需持有锁
+// 返回：
+//   - int64: 当前低水位
+func (t *tracker) done(revision int64) int64 {
+	if t.pending[revision]--; t.pending[revision] <= 0 {
+		delete(t.pending, revision)
+	}
+
+	if len(t.pending) == 0 {
+		return t.dispatched
+	}
+
+	low := t.dispatched + 1
+	for pending := range t.pending {
+		low = min(low, pending)
+	}
+	return low - 1
+}
+
+// fail 登记键处理失败，需持有锁
+// 参数：
+//   - key: 处理失败的键
+//   - revision: 重新回放该键需要的最早版本
+func (t *tracker) fail(key string, revision int64) {
+	t.failed[key] = earliest(t.failed[key], revision)
+}
+
+// resolve 清除键的处理失败，需持有锁
+// 返回：
+//   - bool: 键存在处理失败时为 true
+func (t *tracker) resolve(key string) bool {
+	if _, ok := t.failed[key]; !ok {
+		return false
+	}
+	delete(t.failed, key)
+	return true
+}
+
+// advance 推进检查点，需持有锁
+// 参数：
+//   - revision: 已处理完成的最高版本，0 表示仅在失败清除后重新计算
+//
+// 返回：
+//   - int64: 需要保存的检查点版本，检查点不前进时为 0
+func (t *tracker) advance(revision int64) int64 {
+	t.completed = max(t.completed, revision)
+
+	checkpoint := t.completed
+	for _, failed := range t.failed {
+		checkpoint = min(checkpoint, failed-1)
+	}
+
+	if checkpoint <= t.saved {
+		return 0
+	}
+	t.saved = checkpoint
+	return checkpoint
+}
+
+// earliest 返回两个失败版本中较早的一个，0 表示没有失败
+func earliest(a, b int64) int64 {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
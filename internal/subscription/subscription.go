@@ -0,0 +1,101 @@
+// Package subscription 提供 core.Subscription 的通用实现。
+package subscription
+
+import (
+	"sync"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// Subscription 订阅句柄实现
+type Subscription struct {
+	cancel     func()        // 取消订阅时执行，用于停止监听或移除监听器
+	done       chan struct{} // 订阅结束后关闭
+	mu         sync.Mutex
+	err        error                  // 首个结束原因，done 关闭后才由 Err 返回
+	stats      func() core.QueueStats // 队列统计（可为 nil）
+	cancelOnce sync.Once
+	finishOnce sync.Once
+}
+
+// New 创建订阅句柄
+// 参数：
+//   - cancel: 取消订阅时执行的函数，只会执行一次
+func New(cancel func()) *Subscription {
+	return &Subscription{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+}
+
+// Closed 创建一个已结束的订阅句柄
+func Closed(err error) *Subscription {
+	s := New(nil)
+	s.Finish(err)
+	return s
+}
+
+// Unsubscribe 取消订阅
+func (s *Subscription) Unsubscribe() {
+	s.setErr(core.ErrWatchCanceled)
+	s.cancelOnce.Do(func() {
+		if s.cancel != nil {
+			s.cancel()
+		}
+	})
+}
+
+// Done 订阅结束后关闭的通道
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err 订阅结束的原因
+// 说明：
+//   - Done() 关闭前返回 nil，Unsubscribe 记录的原因在订阅结束后才可见
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+	default:
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Finish 标记订阅结束
+// 说明：
+//   - 由订阅的持有方在回调不再执行后调用
+//   - 已通过 Unsubscribe 记录的原因不会被覆盖
+func (s *Subscription) Finish(err error) {
+	s.setErr(err)
+	s.finishOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// SetStats 设置队列统计的来源
+// 说明：
+//   - 需在订阅句柄返回给调用方之前设置
+func (s *Subscription) SetStats(stats func() core.QueueStats) {
+	s.stats = stats
+}
+
+// Stats 订阅队列统计，未启用队列时返回零值
+func (s *Subscription) Stats() core.QueueStats {
+	if s.stats == nil {
+		return core.QueueStats{}
+	}
+	return s.stats()
+}
+
+// setErr 记录首个结束原因
+func (s *Subscription) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
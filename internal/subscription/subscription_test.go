@@ -0,0 +1,60 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+func TestUnsubscribeReportsAfterFinish(t *testing.T) {
+	canceled := 0
+	s := New(func() { canceled++ })
+
+	s.Unsubscribe()
+	s.Unsubscribe()
+	if canceled != 1 {
+		t.Fatalf("cancel 执行 %d 次, want 1", canceled)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Finish 前 Err = %v, want nil", err)
+	}
+	select {
+	case <-s.Done():
+		t.Fatal("Finish 前 Done 不应关闭")
+	default:
+	}
+
+	// 持有方结束订阅时的原因不覆盖 Unsubscribe
+	s.Finish(core.ErrConnectionClosed)
+	<-s.Done()
+	if err := s.Err(); !errors.Is(err, core.ErrWatchCanceled) {
+		t.Fatalf("Err = %v, want ErrWatchCanceled", err)
+	}
+}
+
+func TestFinish(t *testing.T) {
+	failure := errors.New("监听失败")
+	s := New(nil)
+	s.Finish(failure)
+	s.Finish(core.ErrConnectionClosed)
+	s.Unsubscribe()
+
+	if err := s.Err(); err != failure {
+		t.Fatalf("Err = %v, want %v", err, failure)
+	}
+	if err := Closed(core.ErrConnectionClosed).Err(); !errors.Is(err, core.ErrConnectionClosed) {
+		t.Fatalf("Closed().Err = %v, want ErrConnectionClosed", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := New(nil)
+	if stats := s.Stats(); stats != (core.QueueStats{}) {
+		t.Fatalf("未设置时 Stats = %+v, want 零值", stats)
+	}
+	s.SetStats(func() core.QueueStats { return core.QueueStats{Capacity: 8, Depth: 2} })
+	if stats := s.Stats(); stats.Capacity != 8 || stats.Depth != 2 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+}
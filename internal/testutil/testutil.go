@@ -0,0 +1,23 @@
+// Package testutil 提供各包测试共用的等待辅助函数。
+package testutil
+
+import (
+	"testing"
+	"time"
+)
+
+// Timeout 测试中等待异步结果的最长时间
+const Timeout = 3 * time.Second
+
+// Eventually 等待条件成立，超过 Timeout 时终止测试
+func Eventually(t testing.TB, cond func() bool, format string, args ...any) {
+	t.Helper()
+
+	deadline := time.Now().Add(Timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
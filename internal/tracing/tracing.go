@@ -0,0 +1,283 @@
+// Package tracing 提供从写入到回调的 OpenTelemetry 链路追踪。
+//
+// 写入时在同一事务中把当前链路上下文写入旁路键（TracePrefix + 原键），
+// 两者共享同一版本。处理监听事件时读取旁路键，版本一致时以写入方的链路为父链路，
+// 回调在其子 Span 中执行，从而跨服务串联一次配置变更。
+//
+// 旁路键绑定定期轮换的共享租约，写入后 sideKeyTTL/2 到 sideKeyTTL 内自动删除，不会在 etcd 中累积；
+// 监听方每批事件只读取本批涉及的旁路键，落后超过该时长的事件不再关联写入方链路。
+// 链路使用全局 TracerProvider（go-zero 的 trace 配置会设置），上下文以 W3C Trace Context 传播。
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultPrefix 默认的旁路键前缀
+const DefaultPrefix = "/__etcdtrigger/trace"
+
+// tracerName 链路追踪的 instrumentation 名称
+const tracerName = "github.com/rezeropoint/etcdtrigger"
+
+// sideKeyTTL 旁路键租约的有效期，剩余不足一半时轮换新租约
+const sideKeyTTL = 10 * time.Minute
+
+// lookupConcurrency 每批事件同时读取旁路键的最大数量
+const lookupConcurrency = 8
+
+// propagator 链路上下文的编码方式，不依赖全局 TextMapPropagator 的设置
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Tracer 链路追踪
+// 说明：
+//   - nil 表示未启用，所有方法均可在 nil 上调用：Span 为空操作，写入不携带旁路键
+type Tracer struct {
+	backend core.Backend
+	prefix  string
+	mu      sync.Mutex
+	lease   int64     // 旁路键共享的租约，0 表示尚未创建
+	renewAt time.Time // 到达后为新的写入轮换租约
+}
+
+// New 创建链路追踪
+// 参数：
+//   - backend: 存储后端，用于读取旁路键
+//   - prefix: 旁路键前缀，为空时使用 DefaultPrefix
+func New(backend core.Backend, prefix string) *Tracer {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &Tracer{backend: backend, prefix: prefix}
+}
+
+// Start 创建 Span
+// 参数：
+//   - ctx: 父上下文
+//   - name: Span 名称
+//   - kind: Span 类型
+//   - key: 操作的键，记录为 etcdtrigger.key 属性
+//
+// 返回：
+//   - context.Context: 携带新 Span 的上下文，未启用时为 ctx
+//   - trace.Span: 新 Span，未启用时为空操作
+func (t *Tracer) Start(ctx context.Context, name string, kind trace.SpanKind, key string) (context.Context, trace.Span) {
+	if t == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(attribute.String("etcdtrigger.key", key)),
+	)
+}
+
+// End 结束 Span，err 非 nil 时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Put 写入键，并在同一事务中写入链路上下文
+// 说明：
+//   - 未启用或 ctx 不携带有效链路时等价于 backend.Put
+func (t *Tracer) Put(ctx context.Context, backend core.Backend, key string, value []byte) (int64, error) {
+	carrier := t.inject(ctx)
+	if carrier == nil {
+		return backend.Put(ctx, key, value, 0)
+	}
+
+	return t.commit(ctx, backend, core.Op{Type: core.EventTypePut, Key: key, Value: value}, carrier)
+}
+
+// Delete 删除键，并在同一事务中写入链路上下文
+// 说明：
+//   - 未启用或 ctx 不携带有效链路时等价于 backend.Delete
+//   - 旁路键在删除后保留到租约到期，供监听方关联 DELETE 事件
+func (t *Tracer) Delete(ctx context.Context, backend core.Backend, key string) (int64, error) {
+	carrier := t.inject(ctx)
+	if carrier == nil {
+		return backend.Delete(ctx, key, false)
+	}
+
+	return t.commit(ctx, backend, core.Op{Type: core.EventTypeDelete, Key: key}, carrier)
+}
+
+// Links 一批监听事件对应的旁路键
+// 说明：
+//   - nil 表示未启用链路追踪或事件不需要关联
+type Links map[string]*core.KeyValue
+
+// Lookup 读取一批监听事件的旁路键
+// 参数：
+//   - events: 同一监听响应中的事件
+//
+// 返回：
+//   - Links: 原键 -> 旁路键，未启用时为 nil，读取失败的键不在其中
+//   - error: 任一旁路键读取失败时返回合并的错误
+//
+// 说明：
+//   - 只读取本批事件涉及的键，耗时与批次大小而非监听前缀下的键数相关
+//   - 最多 lookupConcurrency 个读取同时进行，大批次不会对存储发起等量的并发请求
+func (t *Tracer) Lookup(ctx context.Context, events []*core.KvEvent) (Links, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	seen := make(map[string]struct{}, len(events))
+	keys := make(chan string, len(events))
+	for _, ev := range events {
+		if _, ok := seen[ev.Kv.Key]; !ok {
+			seen[ev.Kv.Key] = struct{}{}
+			keys <- ev.Kv.Key
+		}
+	}
+	close(keys)
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		errs  []error
+		links = make(Links, len(seen))
+	)
+	for range min(len(seen), lookupConcurrency) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for key := range keys {
+				resp, err := t.backend.Get(ctx, t.sideKey(key), false)
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				} else if len(resp.Kvs) > 0 {
+					links[key] = resp.Kvs[0]
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return links, errors.Join(errs...)
+}
+
+// Extract 返回事件对应的写入方链路上下文
+// 返回：
+//   - context.Context: 携带写入方链路的上下文；旁路键不存在或版本不一致时为 ctx
+//
+// 说明：
+//   - 仅当旁路键与事件的 ModRevision 相同（同一事务写入）时才关联，避免关联到更新的写入
+func (l Links) Extract(ctx context.Context, event *core.WatchEvent) context.Context {
+	kv, ok := l[event.Key]
+	if !ok || event.ModRevision == 0 || kv.ModRevision != event.ModRevision {
+		return ctx
+	}
+
+	var carrier propagation.MapCarrier
+	if err := jsoniter.Unmarshal(kv.Value, &carrier); err != nil {
+		return ctx
+	}
+	return propagator.Extract(ctx, carrier)
+}
+
+// inject 编码 ctx 中的链路上下文
+// 返回：
+//   - []byte: 编码后的链路上下文，未启用或 ctx 不携带有效链路时为 nil
+func (t *Tracer) inject(ctx context.Context) []byte {
+	if t == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	data, err := jsoniter.Marshal(carrier)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// commit 在同一事务中执行写操作与旁路键写入
+// 说明：
+//   - 无法创建租约或事务失败时不写入旁路键，写操作照常执行
+//   - 删除不存在的键不产生事件，此时不写入旁路键
+func (t *Tracer) commit(ctx context.Context, backend core.Backend, op core.Op, carrier []byte) (int64, error) {
+	lease, err := t.sideLease(ctx, backend)
+	if err != nil {
+		return t.write(ctx, backend, op)
+	}
+
+	txn := &core.Txn{
+		Then: []core.Op{
+			op,
+			{Type: core.EventTypePut, Key: t.sideKey(op.Key), Value: carrier, Lease: lease},
+		},
+	}
+	if op.Type == core.EventTypeDelete {
+		txn.If = []core.Compare{{Key: op.Key, Target: core.CompareCreateRevision, Result: core.CompareGreater, Revision: 0}}
+	}
+	resp, err := backend.Txn(ctx, txn)
+	if err != nil {
+		// 租约可能已失效，下次写入重新创建
+		t.resetLease(lease)
+		return t.write(ctx, backend, op)
+	}
+	return resp.Revision, nil
+}
+
+// write 不携带旁路键执行写操作
+func (t *Tracer) write(ctx context.Context, backend core.Backend, op core.Op) (int64, error) {
+	if op.Type == core.EventTypeDelete {
+		return backend.Delete(ctx, op.Key, false)
+	}
+	return backend.Put(ctx, op.Key, op.Value, 0)
+}
+
+// sideLease 返回旁路键共享的租约，剩余有效期不足一半时创建新租约
+// 说明：
+//   - 旧租约不续约，到期后其旁路键由存储自动删除
+func (t *Tracer) sideLease(ctx context.Context, backend core.Backend) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.lease != 0 && now.Before(t.renewAt) {
+		return t.lease, nil
+	}
+
+	lease, err := backend.Grant(ctx, int64(sideKeyTTL/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	t.lease = lease
+	t.renewAt = now.Add(sideKeyTTL / 2)
+	return lease, nil
+}
+
+// resetLease 丢弃写入失败时使用的租约
+func (t *Tracer) resetLease(lease int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.lease == lease {
+		t.lease = 0
+	}
+}
+
+// sideKey 返回键对应的旁路键
+func (t *Tracer) sideKey(key string) string {
+	return t.prefix + key
+}
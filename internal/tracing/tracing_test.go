@@ -0,0 +1,286 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedContext 返回携带固定链路的上下文
+func tracedContext() context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+// watchEvents 读取 mem 中 prefix 下从 revision 开始的一批事件
+func watchEvents(t *testing.T, mem *backend.Memory, prefix string, revision int64) []*core.KvEvent {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for resp := range mem.Watch(ctx, prefix, core.WatchRequest{Prefix: true, Revision: revision}) {
+		if len(resp.Events) > 0 {
+			return resp.Events
+		}
+	}
+	t.Fatal("监听通道关闭")
+	return nil
+}
+
+func TestTracerLinksWriterSpan(t *testing.T) {
+	mem := backend.NewMemory()
+	tracer := New(mem, "")
+	ctx := tracedContext()
+
+	tests := []struct {
+		name  string
+		write func() (int64, error)
+		key   string
+	}{
+		{
+			name:  "put",
+			write: func() (int64, error) { return tracer.Put(ctx, mem, "/app/a", []byte("1")) },
+			key:   "/app/a",
+		},
+		{
+			name:  "delete",
+			write: func() (int64, error) { return tracer.Delete(ctx, mem, "/app/a") },
+			key:   "/app/a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revision, err := tt.write()
+			if err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			events := watchEvents(t, mem, "/app/", revision)
+			links, err := tracer.Lookup(context.Background(), events)
+			if err != nil {
+				t.Fatalf("Lookup: %v", err)
+			}
+			event := &core.WatchEvent{Key: tt.key, ModRevision: events[0].Kv.ModRevision}
+
+			got := trace.SpanContextFromContext(links.Extract(context.Background(), event))
+			if got.TraceID() != trace.SpanContextFromContext(ctx).TraceID() {
+				t.Fatalf("TraceID = %v, want %v", got.TraceID(), trace.SpanContextFromContext(ctx).TraceID())
+			}
+
+			// 版本不一致时不关联
+			stale := &core.WatchEvent{Key: tt.key, ModRevision: revision - 1}
+			if trace.SpanContextFromContext(links.Extract(context.Background(), stale)).IsValid() {
+				t.Fatal("版本不一致的事件关联了写入方链路")
+			}
+		})
+	}
+}
+
+func TestTracerSideKeysShareLease(t *testing.T) {
+	mem := backend.NewMemory()
+	tracer := New(mem, "/trace")
+	ctx := tracedContext()
+
+	for _, key := range []string{"/app/a", "/app/b"} {
+		if _, err := tracer.Put(ctx, mem, key, []byte("1")); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	resp, err := mem.Get(context.Background(), "/trace/app/", true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(resp.Kvs) != 2 {
+		t.Fatalf("旁路键数 = %d, want 2", len(resp.Kvs))
+	}
+	if resp.Kvs[0].Lease == 0 || resp.Kvs[0].Lease != resp.Kvs[1].Lease {
+		t.Fatalf("旁路键租约 = %d, %d, want 相同且非 0", resp.Kvs[0].Lease, resp.Kvs[1].Lease)
+	}
+
+	// 租约撤销后旁路键随之删除
+	revoked := resp.Kvs[0].Lease
+	if err := mem.Revoke(context.Background(), revoked); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	resp, _ = mem.Get(context.Background(), "/trace/", true)
+	if len(resp.Kvs) != 0 {
+		t.Fatalf("租约撤销后仍有 %d 个旁路键", len(resp.Kvs))
+	}
+
+	// 租约失效时事务失败，写入照常执行但不携带旁路键，随后重新创建租约
+	if _, err := tracer.Put(ctx, mem, "/app/a", []byte("2")); err != nil {
+		t.Fatalf("租约失效时写入: %v", err)
+	}
+	value, _ := mem.Get(context.Background(), "/app/a", false)
+	side, _ := mem.Get(context.Background(), "/trace/app/a", false)
+	if len(value.Kvs) != 1 || string(value.Kvs[0].Value) != "2" || len(side.Kvs) != 0 {
+		t.Fatalf("租约失效时写入 = %v, 旁路键 = %v, want 值 2 且无旁路键", value.Kvs, side.Kvs)
+	}
+	if _, err := tracer.Put(ctx, mem, "/app/a", []byte("3")); err != nil {
+		t.Fatalf("重新创建租约后写入: %v", err)
+	}
+	side, _ = mem.Get(context.Background(), "/trace/app/a", false)
+	if len(side.Kvs) != 1 || side.Kvs[0].Lease == revoked {
+		t.Fatalf("重新创建租约后旁路键 = %v, want 绑定新租约", side.Kvs)
+	}
+}
+
+func TestTracerDeleteMissingKey(t *testing.T) {
+	mem := backend.NewMemory()
+	tracer := New(mem, "/trace")
+
+	before, _ := mem.Get(context.Background(), "/", true)
+	revision, err := tracer.Delete(tracedContext(), mem, "/app/missing")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if revision != before.Revision {
+		t.Fatalf("删除不存在的键后版本 = %d, want %d", revision, before.Revision)
+	}
+
+	resp, _ := mem.Get(context.Background(), "/trace/", true)
+	if len(resp.Kvs) != 0 {
+		t.Fatalf("删除不存在的键写入了 %d 个旁路键", len(resp.Kvs))
+	}
+}
+
+// getRecorder 记录读取的键，failing 为 true 时读取失败
+type getRecorder struct {
+	*backend.Memory
+	mu      sync.Mutex
+	gets    []string
+	failing bool
+}
+
+func (b *getRecorder) Get(ctx context.Context, key string, prefix bool) (*core.GetResult, error) {
+	b.mu.Lock()
+	b.gets = append(b.gets, fmt.Sprintf("%s prefix=%v", key, prefix))
+	failing := b.failing
+	b.mu.Unlock()
+
+	if failing {
+		return nil, errors.New("etcd 不可用")
+	}
+	return b.Memory.Get(ctx, key, prefix)
+}
+
+func TestTracerLookupReadsBatchKeys(t *testing.T) {
+	mem := backend.NewMemory()
+	b := &getRecorder{Memory: mem}
+	tracer := New(b, "/trace")
+	ctx := tracedContext()
+
+	// 监听前缀下的其他旁路键不应被读取
+	if _, err := tracer.Put(ctx, mem, "/app/other", []byte("1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	revision, err := tracer.Put(ctx, mem, "/app/a", []byte("1"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := tracer.Put(ctx, mem, "/app/b", []byte("1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	events := watchEvents(t, mem, "/app/", revision)
+	events = append(events, watchEvents(t, mem, "/app/", revision+1)...)
+	links, err := tracer.Lookup(context.Background(), events)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if len(links) != 2 || links["/app/a"] == nil || links["/app/b"] == nil {
+		t.Fatalf("Lookup = %v, want /app/a 与 /app/b", links)
+	}
+
+	slices.Sort(b.gets)
+	if want := []string{"/trace/app/a prefix=false", "/trace/app/b prefix=false"}; !slices.Equal(b.gets, want) {
+		t.Fatalf("读取 = %v, want %v", b.gets, want)
+	}
+
+	b.failing = true
+	links, err = tracer.Lookup(context.Background(), events)
+	if err == nil || len(links) != 0 {
+		t.Fatalf("读取失败时 Lookup = %v, %v, want 空与错误", links, err)
+	}
+}
+
+// concurrentGets 记录同时进行的读取数的峰值
+type concurrentGets struct {
+	*backend.Memory
+	mu       sync.Mutex
+	inflight int
+	peak     int
+}
+
+func (b *concurrentGets) Get(ctx context.Context, key string, prefix bool) (*core.GetResult, error) {
+	b.mu.Lock()
+	b.inflight++
+	b.peak = max(b.peak, b.inflight)
+	b.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	b.mu.Lock()
+	b.inflight--
+	b.mu.Unlock()
+	return b.Memory.Get(ctx, key, prefix)
+}
+
+func TestTracerLookupBoundsConcurrency(t *testing.T) {
+	mem := backend.NewMemory()
+	b := &concurrentGets{Memory: mem}
+	tracer := New(b, "/trace")
+	ctx := tracedContext()
+
+	var events []*core.KvEvent
+	for i := range 4 * lookupConcurrency {
+		key := fmt.Sprintf("/app/%d", i)
+		revision, err := tracer.Put(ctx, mem, key, []byte("1"))
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		events = append(events, &core.KvEvent{Type: core.EventTypePut, Kv: &core.KeyValue{Key: key, ModRevision: revision}})
+	}
+
+	links, err := tracer.Lookup(context.Background(), events)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if len(links) != len(events) {
+		t.Fatalf("Lookup 返回 %d 个旁路键, want %d", len(links), len(events))
+	}
+	if b.peak > lookupConcurrency {
+		t.Fatalf("同时读取数峰值 = %d, want 不超过 %d", b.peak, lookupConcurrency)
+	}
+}
+
+func TestTracerDisabled(t *testing.T) {
+	mem := backend.NewMemory()
+	var tracer *Tracer
+
+	if _, err := tracer.Put(tracedContext(), mem, "/app/a", []byte("1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if links, err := tracer.Lookup(context.Background(), nil); links != nil || err != nil {
+		t.Fatalf("Lookup = %v, %v, want nil", links, err)
+	}
+
+	resp, _ := mem.Get(context.Background(), "/", true)
+	if len(resp.Kvs) != 1 {
+		t.Fatalf("键数 = %d, want 1（不写入旁路键）", len(resp.Kvs))
+	}
+}
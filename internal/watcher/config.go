@@ -1,4 +1,15 @@
 package watcher
 
+import (
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/metrics"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/tracing"
+)
+
 // Config 监听管理器配置
-type Config struct{}
+type Config struct {
+	OnWatchState core.WatchStateCallback // 监听状态回调（可为 nil）
+	Metrics      *metrics.Metrics        // 指标记录器（可为 nil）
+	Tracer       *tracing.Tracer         // 链路追踪（可为 nil）
+	OnError      core.ErrorCallback      // 回调出错的错误回调（可为 nil）
+}
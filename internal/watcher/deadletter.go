@@ -0,0 +1,360 @@
+package watcher
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/stream"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond // 首次重试前的默认等待
+	defaultMaxBackoff     = 10 * time.Second       // 默认最长重试等待
+)
+
+// subscriber 订阅的回调与失败处理策略
+type subscriber struct {
+	id         string // 订阅 ID，用作指标标签
+	key        string
+	callback   core.WatchCallback
+	retry      core.RetryPolicy
+	deadLetter string         // 死信前缀，为空时不写入死信
+	stream     *stream.Stream // 订阅的监听流，补写死信后据此清除键的处理失败
+
+	mu        sync.Mutex
+	unwritten map[string][]*core.DeadLetter // 事件键 -> 写入失败、等待补写的死信
+}
+
+// register 登记订阅及其死信前缀，用于校验死信前缀与重放死信
+// 返回：
+//   - error: 死信前缀位于任一订阅的监听前缀之下、监听前缀包含其他订阅的死信前缀，
+//     或死信前缀已被其他订阅使用时返回 ErrInvalidConfig
+//
+// 说明：
+//   - 死信写入监听前缀之下会再次触发订阅，失败的事件因此反复写入死信
+func (m *watcherManager) register(s *subscriber) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.deadLetter != "" {
+		if _, loaded := m.deadLetters.Load(s.deadLetter); loaded {
+			return fmt.Errorf("%w: 死信前缀 %q 已被其他订阅使用", core.ErrInvalidConfig, s.deadLetter)
+		}
+		if strings.HasPrefix(s.deadLetter, s.key) {
+			return fmt.Errorf("%w: 死信前缀 %q 不能位于监听前缀 %q 之下", core.ErrInvalidConfig, s.deadLetter, s.key)
+		}
+	}
+	for other := range m.subscribers {
+		if s.deadLetter != "" && strings.HasPrefix(s.deadLetter, other.key) {
+			return fmt.Errorf("%w: 死信前缀 %q 不能位于监听前缀 %q 之下", core.ErrInvalidConfig, s.deadLetter, other.key)
+		}
+		if other.deadLetter != "" && strings.HasPrefix(other.deadLetter, s.key) {
+			return fmt.Errorf("%w: 监听前缀 %q 不能包含死信前缀 %q", core.ErrInvalidConfig, s.key, other.deadLetter)
+		}
+	}
+
+	if m.subscribers == nil {
+		m.subscribers = make(map[*subscriber]struct{})
+	}
+	m.subscribers[s] = struct{}{}
+	if s.deadLetter != "" {
+		m.deadLetters.Store(s.deadLetter, s)
+	}
+	return nil
+}
+
+// unregister 注销订阅及其死信前缀
+func (m *watcherManager) unregister(s *subscriber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.subscribers, s)
+	if s.deadLetter != "" {
+		m.deadLetters.CompareAndDelete(s.deadLetter, s)
+	}
+}
+
+// process 执行订阅回调，失败时按重试策略重试，重试耗尽后写入死信
+// 返回：
+//   - error: 重试耗尽且未写入死信，或等待重试期间 ctx 取消时返回最后一次的错误
+//
+// 说明：
+//   - 死信写入失败时保留在内存中，同一键的下一个事件处理前补写
+func (m *watcherManager) process(ctx context.Context, s *subscriber, event *core.WatchEvent) error {
+	m.flushDeadLetters(ctx, s, event.Key)
+
+	attempts, err := m.attempt(ctx, s, event)
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return err
+	}
+
+	log := m.logCtx.WithContext(event.Context(), "watcher", "subscribe").WithFields(core.Field("key", event.Key), core.Field("event_type", event.EventType), core.Field("revision", event.Revision), core.Field("attempts", attempts))
+
+	log.WithFields(core.Field("error", err.Error())).Error("处理事件失败")
+	m.notify(s.key, &core.CallbackError{Key: event.Key, Revision: event.Revision, Err: err})
+	if s.deadLetter == "" {
+		return err
+	}
+
+	letter := newDeadLetter(s.key, event, attempts, err)
+	if dlErr := m.putDeadLetter(ctx, s.deadLetter, letter); dlErr != nil {
+		log.WithFields(core.Field("dead_letter", s.deadLetter), core.Field("error", dlErr.Error())).Error("写入死信失败，等待补写")
+		s.mu.Lock()
+		if s.unwritten == nil {
+			s.unwritten = make(map[string][]*core.DeadLetter)
+		}
+		s.unwritten[event.Key] = append(s.unwritten[event.Key], letter)
+		s.mu.Unlock()
+		return err
+	}
+
+	m.config.Metrics.DeadLetter(s.key, s.id)
+	log.WithFields(core.Field("dead_letter", s.deadLetter+letter.ID)).Info("已写入死信")
+	return nil
+}
+
+// flushDeadLetters 补写键之前写入失败的死信
+// 说明：
+//   - 同一键的事件由同一协程按顺序处理，补写时该键此前的失败已登记到监听流
+//   - 键的死信全部写入后清除该键的处理失败，检查点恢复推进
+func (m *watcherManager) flushDeadLetters(ctx context.Context, s *subscriber, key string) {
+	s.mu.Lock()
+	letters := s.unwritten[key]
+	delete(s.unwritten, key)
+	s.mu.Unlock()
+
+	for i, letter := range letters {
+		if err := m.putDeadLetter(ctx, s.deadLetter, letter); err != nil {
+			s.mu.Lock()
+			s.unwritten[key] = append(letters[i:], s.unwritten[key]...)
+			s.mu.Unlock()
+			return
+		}
+		m.config.Metrics.DeadLetter(s.key, s.id)
+		m.log("subscribe").WithFields(core.Field("key", key), core.Field("revision", letter.Revision), core.Field("dead_letter", s.deadLetter+letter.ID)).Info("已补写死信")
+	}
+
+	if len(letters) > 0 {
+		s.stream.Resolve(ctx, key)
+	}
+}
+
+// attempt 按重试策略执行回调
+// 返回：
+//   - int: 尝试次数
+//   - error: 最后一次的错误，成功时为 nil
+func (m *watcherManager) attempt(ctx context.Context, s *subscriber, event *core.WatchEvent) (int, error) {
+	maxAttempts := max(s.retry.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		err := m.invoke(s, event)
+		if err == nil || attempt >= maxAttempts {
+			return attempt, err
+		}
+
+		m.logCtx.WithContext(event.Context(), "watcher", "subscribe").WithFields(core.Field("key", event.Key), core.Field("revision", event.Revision), core.Field("attempt", attempt), core.Field("error", err.Error())).Info("处理事件失败，等待重试")
+		m.config.Metrics.Retry(s.key, s.id)
+
+		timer := time.NewTimer(retryDelay(s.retry, attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		}
+	}
+}
+
+// retryDelay 计算第 attempt 次失败后带抖动的重试等待
+// 说明：
+//   - 基准时长从 InitialBackoff 开始逐次翻倍，不超过 MaxBackoff
+//   - 实际等待在基准时长的 [1/2, 1] 区间内随机
+func retryDelay(policy core.RetryPolicy, attempt int) time.Duration {
+	maxDelay := cmp.Or(policy.MaxBackoff, defaultMaxBackoff)
+	delay := min(cmp.Or(policy.InitialBackoff, defaultInitialBackoff), maxDelay)
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay = min(delay*2, maxDelay)
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// ListDeadLetters 列出死信前缀下的死信
+func (m *watcherManager) ListDeadLetters(ctx context.Context, prefix string) ([]*core.DeadLetter, error) {
+	if m.backend == nil || m.group.Closed() {
+		return nil, core.ErrConnectionClosed
+	}
+
+	if prefix == "" {
+		return nil, core.ErrConfigEmpty
+	}
+
+	resp, err := m.backend.Get(ctx, prefix, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", core.ErrGetFailed, err)
+	}
+
+	letters := make([]*core.DeadLetter, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		letter := &core.DeadLetter{}
+		if err := jsoniter.Unmarshal(kv.Value, letter); err != nil {
+			m.log("list_dead_letters").WithFields(core.Field("key", kv.Key), core.Field("error", err.Error())).Error("解析死信失败，已跳过")
+			continue
+		}
+		// 键名须与死信内容对应，避免把前缀下恰好能解析的其他 JSON 当作死信
+		letter.ID = strings.TrimPrefix(kv.Key, prefix)
+		if letter.ID != deadLetterID(letter.Revision, letter.Key) {
+			m.log("list_dead_letters").WithFields(core.Field("key", kv.Key)).Debug("不是死信，已跳过")
+			continue
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// RedriveDeadLetters 将死信重新交给订阅回调处理
+func (m *watcherManager) RedriveDeadLetters(ctx context.Context, prefix string, ids ...string) (int, error) {
+	release, ok := m.group.Acquire()
+	if !ok {
+		return 0, core.ErrConnectionClosed
+	}
+	defer release()
+
+	value, ok := m.deadLetters.Load(prefix)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", core.ErrDeadLetterNoWatcher, prefix)
+	}
+	s := value.(*subscriber)
+
+	letters, err := m.ListDeadLetters(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		redriven int
+		errs     []error
+	)
+	for _, letter := range filterDeadLetters(letters, ids) {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		event := letter.Event()
+		attempts, err := m.attempt(ctx, s, event)
+		if err != nil {
+			letter.Attempts += attempts
+			letter.Error = err.Error()
+			letter.FailedAt = time.Now()
+			if putErr := m.putDeadLetter(ctx, prefix, letter); putErr != nil {
+				errs = append(errs, putErr)
+			}
+			errs = append(errs, &core.CallbackError{Key: event.Key, Revision: event.Revision, Err: err})
+			continue
+		}
+
+		if _, err := m.backend.Delete(ctx, prefix+letter.ID, false); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %v", core.ErrDeleteFailed, err))
+			continue
+		}
+		redriven++
+	}
+
+	m.log("redrive_dead_letters").WithFields(core.Field("prefix", prefix), core.Field("redriven", redriven), core.Field("failed", len(errs))).Info("重放死信完成")
+	return redriven, errors.Join(errs...)
+}
+
+// PurgeDeadLetters 删除死信
+// 说明：
+//   - 与 ListDeadLetters 相同，不要求当前存在使用该死信前缀的订阅
+//   - 逐条删除 ListDeadLetters 返回的死信，不会删除前缀下的其他键
+func (m *watcherManager) PurgeDeadLetters(ctx context.Context, prefix string, ids ...string) (int, error) {
+	release, ok := m.group.Acquire()
+	if !ok {
+		return 0, core.ErrConnectionClosed
+	}
+	defer release()
+
+	letters, err := m.ListDeadLetters(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, letter := range filterDeadLetters(letters, ids) {
+		if _, err := m.backend.Delete(ctx, prefix+letter.ID, false); err != nil {
+			return purged, fmt.Errorf("%w: %v", core.ErrDeleteFailed, err)
+		}
+		purged++
+	}
+
+	m.log("purge_dead_letters").WithFields(core.Field("prefix", prefix), core.Field("purged", purged)).Info("清除死信成功")
+	return purged, nil
+}
+
+// putDeadLetter 写入死信
+func (m *watcherManager) putDeadLetter(ctx context.Context, prefix string, letter *core.DeadLetter) error {
+	data, err := jsoniter.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("%w: %v", core.ErrDeadLetterWriteFailed, err)
+	}
+
+	if _, err := m.backend.Put(ctx, prefix+letter.ID, data, 0); err != nil {
+		return fmt.Errorf("%w: %v", core.ErrDeadLetterWriteFailed, err)
+	}
+	return nil
+}
+
+// newDeadLetter 由失败的事件创建死信
+func newDeadLetter(subscription string, event *core.WatchEvent, attempts int, err error) *core.DeadLetter {
+	return &core.DeadLetter{
+		ID:             deadLetterID(event.Revision, event.Key),
+		Subscription:   subscription,
+		Key:            event.Key,
+		Value:          event.Value,
+		PrevValue:      event.PrevValue,
+		EventType:      event.EventType,
+		Revision:       event.Revision,
+		CreateRevision: event.CreateRevision,
+		ModRevision:    event.ModRevision,
+		Version:        event.Version,
+		Lease:          event.Lease,
+		Attempts:       attempts,
+		Error:          err.Error(),
+		FailedAt:       time.Now(),
+	}
+}
+
+// deadLetterID 返回死信 ID，由 20 位补零的事件版本与事件键组成，按字典序即按版本排序
+func deadLetterID(revision int64, key string) string {
+	return fmt.Sprintf("%020d%s", revision, key)
+}
+
+// filterDeadLetters 按 ID 筛选死信，ids 为空时返回全部
+func filterDeadLetters(letters []*core.DeadLetter, ids []string) []*core.DeadLetter {
+	if len(ids) == 0 {
+		return letters
+	}
+
+	filtered := make([]*core.DeadLetter, 0, len(ids))
+	for _, letter := range letters {
+		if slices.Contains(ids, letter.ID) {
+			filtered = append(filtered, letter)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,327 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/checkpoint"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/testutil"
+)
+
+// flakyHandler 对 failing 中的键返回错误
+type flakyHandler struct {
+	mu      sync.Mutex
+	failing map[string]bool
+}
+
+func (h *flakyHandler) handle(event *core.WatchEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.failing[event.Key] {
+		return errors.New("处理失败")
+	}
+	return nil
+}
+
+func (h *flakyHandler) fix(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.failing, key)
+}
+
+// newTestManager 创建基于内存后端的监听管理器，测试结束时关闭
+func newTestManager(t *testing.T, b core.Backend) *watcherManager {
+	t.Helper()
+
+	m := newManager(b, &core.LogContext{}, &Config{})
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.Timeout)
+		defer cancel()
+		if err := m.Close(ctx); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+	return m
+}
+
+// listDeadLetters 等待死信数量达到 n 后返回
+func listDeadLetters(t *testing.T, m *watcherManager, prefix string, n int) []*core.DeadLetter {
+	t.Helper()
+
+	deadline := time.Now().Add(testutil.Timeout)
+	for {
+		letters, err := m.ListDeadLetters(context.Background(), prefix)
+		if err != nil {
+			t.Fatalf("ListDeadLetters: %v", err)
+		}
+		if len(letters) == n {
+			return letters
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("死信数量 = %d, want %d", len(letters), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem)
+
+	h := &flakyHandler{failing: map[string]bool{"/jobs/a": true, "/jobs/b": true}}
+	_, err := m.Watch("/jobs/", h.handle,
+		core.WithRetry(core.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+		core.WithDeadLetter("/dlq/"),
+	)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	a, _ := mem.Put(ctx, "/jobs/a", []byte("1"), 0)
+	b, _ := mem.Put(ctx, "/jobs/b", []byte("1"), 0)
+
+	// 重试耗尽后写入死信，按事件版本排序
+	letters := listDeadLetters(t, m, "/dlq/", 2)
+	for i, want := range []struct {
+		key      string
+		revision int64
+	}{{"/jobs/a", a}, {"/jobs/b", b}} {
+		letter := letters[i]
+		if letter.Key != want.key || letter.Revision != want.revision || letter.Subscription != "/jobs/" {
+			t.Fatalf("死信 %d = %s@%d (%s), want %s@%d (/jobs/)", i, letter.Key, letter.Revision, letter.Subscription, want.key, want.revision)
+		}
+		if letter.Attempts != 2 || letter.Error != "处理失败" || string(letter.Value) != "1" {
+			t.Fatalf("死信 %d = %+v", i, letter)
+		}
+	}
+
+	// 仍然失败的死信保留并累计尝试次数，成功的死信被删除
+	h.fix("/jobs/b")
+	redriven, err := m.RedriveDeadLetters(ctx, "/dlq/")
+	var callbackErr *core.CallbackError
+	if redriven != 1 || !errors.As(err, &callbackErr) || callbackErr.Key != "/jobs/a" {
+		t.Fatalf("RedriveDeadLetters = %d, %v, want 1 与 /jobs/a 的 CallbackError", redriven, err)
+	}
+	letters = listDeadLetters(t, m, "/dlq/", 1)
+	if letters[0].Key != "/jobs/a" || letters[0].Attempts != 4 {
+		t.Fatalf("剩余死信 = %s (attempts %d), want /jobs/a (attempts 4)", letters[0].Key, letters[0].Attempts)
+	}
+
+	// 按 ID 重放与清除
+	h.fix("/jobs/a")
+	if redriven, err := m.RedriveDeadLetters(ctx, "/dlq/", "missing"); redriven != 0 || err != nil {
+		t.Fatalf("重放不存在的 ID = %d, %v", redriven, err)
+	}
+	if purged, err := m.PurgeDeadLetters(ctx, "/dlq/", "missing"); purged != 0 || err != nil {
+		t.Fatalf("清除不存在的 ID = %d, %v", purged, err)
+	}
+	if purged, err := m.PurgeDeadLetters(ctx, "/dlq/", letters[0].ID); purged != 1 || err != nil {
+		t.Fatalf("PurgeDeadLetters = %d, %v, want 1", purged, err)
+	}
+	listDeadLetters(t, m, "/dlq/", 0)
+
+	if _, err := m.RedriveDeadLetters(ctx, "/other-dlq/"); !errors.Is(err, core.ErrDeadLetterNoWatcher) {
+		t.Fatalf("重放无订阅的前缀 = %v, want ErrDeadLetterNoWatcher", err)
+	}
+}
+
+func TestPurgeAllDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem)
+
+	h := &flakyHandler{failing: map[string]bool{"/jobs/a": true, "/jobs/b": true}}
+	if _, err := m.Watch("/jobs/", h.handle, core.WithDeadLetter("/dlq/")); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	_, _ = mem.Put(ctx, "/jobs/a", []byte("1"), 0)
+	_, _ = mem.Put(ctx, "/jobs/b", []byte("1"), 0)
+	letters := listDeadLetters(t, m, "/dlq/", 2)
+	if letters[0].Attempts != 1 {
+		t.Fatalf("未启用重试时 Attempts = %d, want 1", letters[0].Attempts)
+	}
+	_, _ = mem.Put(ctx, "/dlq/corrupt", []byte("not json"), 0)
+	_, _ = mem.Put(ctx, "/dlq/other", []byte(`{"key":"/jobs/a","revision":1}`), 0)
+
+	if _, err := m.PurgeDeadLetters(ctx, ""); !errors.Is(err, core.ErrConfigEmpty) {
+		t.Fatalf("PurgeDeadLetters(\"\") = %v, want ErrConfigEmpty", err)
+	}
+	// 不是死信前缀时只跳过其下的键，不会误删
+	for _, prefix := range []string{"/", "/jobs/"} {
+		if purged, err := m.PurgeDeadLetters(ctx, prefix); purged != 0 || err != nil {
+			t.Fatalf("PurgeDeadLetters(%q) = %d, %v, want 0", prefix, purged, err)
+		}
+	}
+
+	if purged, err := m.PurgeDeadLetters(ctx, "/dlq/"); purged != 2 || err != nil {
+		t.Fatalf("PurgeDeadLetters = %d, %v, want 2", purged, err)
+	}
+	listDeadLetters(t, m, "/dlq/", 0)
+
+	resp, err := mem.Get(ctx, "/", true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	var keys []string
+	for _, kv := range resp.Kvs {
+		keys = append(keys, kv.Key)
+	}
+	if want := []string{"/dlq/corrupt", "/dlq/other", "/jobs/a", "/jobs/b"}; !slices.Equal(keys, want) {
+		t.Fatalf("清除后剩余的键 = %v, want %v", keys, want)
+	}
+
+	closeCtx, cancel := context.WithTimeout(ctx, testutil.Timeout)
+	defer cancel()
+	if err := m.Close(closeCtx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := m.PurgeDeadLetters(ctx, "/dlq/"); !errors.Is(err, core.ErrConnectionClosed) {
+		t.Fatalf("关闭后 PurgeDeadLetters = %v, want ErrConnectionClosed", err)
+	}
+}
+
+func TestPurgeDeadLettersWithoutSubscription(t *testing.T) {
+	ctx := context.Background()
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem)
+
+	h := &flakyHandler{failing: map[string]bool{"/jobs/a": true}}
+	sub, err := m.Watch("/jobs/", h.handle, core.WithDeadLetter("/dlq/"))
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	_, _ = mem.Put(ctx, "/jobs/a", []byte("1"), 0)
+	listDeadLetters(t, m, "/dlq/", 1)
+
+	sub.Unsubscribe()
+	<-sub.Done()
+
+	// 订阅结束后仍可清除死信，但不能重放
+	if _, err := m.RedriveDeadLetters(ctx, "/dlq/"); !errors.Is(err, core.ErrDeadLetterNoWatcher) {
+		t.Fatalf("RedriveDeadLetters = %v, want ErrDeadLetterNoWatcher", err)
+	}
+	if purged, err := m.PurgeDeadLetters(ctx, "/dlq/"); purged != 1 || err != nil {
+		t.Fatalf("PurgeDeadLetters = %d, %v, want 1", purged, err)
+	}
+	listDeadLetters(t, m, "/dlq/", 0)
+}
+
+func TestDeadLetterPrefixValidation(t *testing.T) {
+	m := newTestManager(t, backend.NewMemory())
+	callback := func(*core.WatchEvent) error { return nil }
+
+	if _, err := m.Watch("/jobs/", callback, core.WithDeadLetter("/dlq/")); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		deadLetter string
+	}{
+		{name: "under own watch prefix", key: "/tasks/", deadLetter: "/tasks/dlq/"},
+		{name: "under other watch prefix", key: "/tasks/", deadLetter: "/jobs/dlq/"},
+		{name: "watch prefix covers dead letters", key: "/"},
+		{name: "already used", key: "/tasks/", deadLetter: "/dlq/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []core.WatchOption
+			if tt.deadLetter != "" {
+				opts = append(opts, core.WithDeadLetter(tt.deadLetter))
+			}
+			if _, err := m.Watch(tt.key, callback, opts...); !errors.Is(err, core.ErrInvalidConfig) {
+				t.Fatalf("Watch = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+
+	// 被拒绝的订阅不会登记，使用不重叠的前缀可以订阅
+	if _, err := m.Watch("/tasks/", callback, core.WithDeadLetter("/tasks-dlq/")); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  core.RetryPolicy
+		attempt int
+		want    time.Duration // 基准时长，实际等待在 [want/2, want]
+	}{
+		{name: "default initial", attempt: 1, want: defaultInitialBackoff},
+		{name: "doubles", policy: core.RetryPolicy{InitialBackoff: 10 * time.Millisecond}, attempt: 3, want: 40 * time.Millisecond},
+		{name: "capped", policy: core.RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 25 * time.Millisecond}, attempt: 5, want: 25 * time.Millisecond},
+		{name: "initial above max", policy: core.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 50 * time.Millisecond}, attempt: 1, want: 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				if got := retryDelay(tt.policy, tt.attempt); got < tt.want/2 || got > tt.want {
+					t.Fatalf("retryDelay() = %v, want [%v, %v]", got, tt.want/2, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// deadLetterBackend down 为 true 时死信前缀下的写入失败
+type deadLetterBackend struct {
+	*backend.Memory
+	down atomic.Bool
+}
+
+func (b *deadLetterBackend) Put(ctx context.Context, key string, value []byte, lease int64) (int64, error) {
+	if b.down.Load() && strings.HasPrefix(key, "/dlq/") {
+		return 0, errors.New("etcd 不可用")
+	}
+	return b.Memory.Put(ctx, key, value, lease)
+}
+
+func TestDeadLetterRewriteResumesCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	b := &deadLetterBackend{Memory: backend.NewMemory()}
+	m := newTestManager(t, b)
+	checkpoints := checkpoint.NewBackendStore(b.Memory, "/checkpoints/")
+	load := func() int64 {
+		revision, _ := checkpoints.Load(ctx, "/jobs/")
+		return revision
+	}
+
+	h := &flakyHandler{failing: map[string]bool{"/jobs/a": true}}
+	_, err := m.Watch("/jobs/", h.handle, core.WithDeadLetter("/dlq/"), core.WithCheckpoint(checkpoints))
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	initial := load()
+
+	// 死信写入失败时检查点停在失败的事件之前
+	b.down.Store(true)
+	a, _ := b.Put(ctx, "/jobs/a", []byte("1"), 0)
+	b.Put(ctx, "/jobs/b", []byte("1"), 0)
+	time.Sleep(50 * time.Millisecond)
+	if got := load(); got >= a {
+		t.Fatalf("死信写入失败后检查点 = %d, want 早于 %d (初始 %d)", got, a, initial)
+	}
+
+	// 同一键的下一个事件补写死信，检查点恢复推进
+	b.down.Store(false)
+	last, _ := b.Put(ctx, "/jobs/a", []byte("2"), 0)
+	letters := listDeadLetters(t, m, "/dlq/", 2)
+	if letters[0].Revision != a || letters[1].Revision != last {
+		t.Fatalf("死信版本 = %d, %d, want %d, %d", letters[0].Revision, letters[1].Revision, a, last)
+	}
+	testutil.Eventually(t, func() bool { return load() == last }, "补写死信后检查点未推进到 %d", last)
+}
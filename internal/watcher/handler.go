@@ -2,96 +2,190 @@ package watcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rezeropoint/etcdtrigger/v2/core"
-	"github.com/zeromicro/go-zero/core/logx"
-	clientv3 "go.etcd.io/etcd/client/v3"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/dispatch"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/lifecycle"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/metrics"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/recovery"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/stream"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/subscription"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // watcherManager 监听管理器实现
 type watcherManager struct {
-	client *clientv3.Client
-	logCtx *core.LogContext
+	backend     core.Backend
+	logCtx      *core.LogContext
+	config      *Config
+	group       *lifecycle.Group         // 监听协程与回调的生命周期
+	deadLetters sync.Map                 // 死信前缀 -> *subscriber，用于重放死信
+	seq         atomic.Uint64            // 订阅 ID 序列
+	mu          sync.Mutex               // 使前缀校验与登记原子完成
+	subscribers map[*subscriber]struct{} // 活跃的订阅，用于校验死信前缀
 }
 
 // newManager 创建监听管理器实例
-func newManager(client *clientv3.Client, logCtx *core.LogContext, _ *Config) *watcherManager {
+func newManager(backend core.Backend, logCtx *core.LogContext, config *Config) *watcherManager {
 	return &watcherManager{
-		client: client,
-		logCtx: logCtx,
+		backend: backend,
+		logCtx:  logCtx,
+		config:  config,
+		group:   lifecycle.NewGroup(),
 	}
 }
 
 // Watch 订阅配置变更
-func (m *watcherManager) Watch(key string, callback core.WatchCallback) error {
-	if m.client == nil {
-		return core.ErrConnectionClosed
+func (m *watcherManager) Watch(key string, callback core.WatchCallback, opts ...core.WatchOption) (core.Subscription, error) {
+	if m.backend == nil {
+		return nil, core.ErrConnectionClosed
 	}
 
 	if key == "" {
-		return core.ErrConfigEmpty
+		return nil, core.ErrConfigEmpty
 	}
 
-	ctx := context.Background()
+	release, ok := m.group.Acquire()
+	if !ok {
+		return nil, core.ErrConnectionClosed
+	}
+	defer release()
 
-	// 获取当前值
-	resp, err := m.client.Get(ctx, key, clientv3.WithPrefix())
-	if err != nil {
-		return fmt.Errorf("%w: %v", core.ErrGetFailed, err)
+	ctx, cancel := context.WithCancel(m.group.Context())
+	sub := subscription.New(cancel)
+
+	options := core.NewWatchOptions(opts...)
+	if err := options.Validate(); err != nil {
+		cancel()
+		return nil, err
 	}
 
-	// 处理当前值
-	for _, kv := range resp.Kvs {
-		event := &core.WatchEvent{
-			Key:       string(kv.Key),
-			Value:     kv.Value,
-			EventType: core.EventTypePut,
-		}
-		if err := callback(event); err != nil {
-			m.log("subscribe").WithFields(logx.Field("key", string(kv.Key)), logx.Field("error", err.Error())).Error("处理键值失败")
+	target := &subscriber{
+		id:         fmt.Sprintf("watch-%d", m.seq.Add(1)),
+		key:        key,
+		callback:   callback,
+		retry:      options.Retry,
+		deadLetter: options.DeadLetter,
+	}
+	if err := m.register(target); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// 启用队列或工作协程时回调在独立的协程中执行
+	async := options.QueueSize > 0 || options.Workers > 1
+	streamConfig := &stream.Config{
+		Prefix:         key,
+		OnState:        m.config.OnWatchState,
+		Checkpoint:     options.Checkpoint,
+		CheckpointName: options.CheckpointName,
+		PrevKV:         options.PrevValue,
+		Metrics:        m.config.Metrics,
+		Tracer:         m.config.Tracer,
+		Async:          async,
+	}
+	handle := func(event *core.WatchEvent) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+		return m.process(ctx, target, event)
 	}
 
-	// 监听后续变更
-	watchChan := m.client.Watch(ctx, key, clientv3.WithPrefix())
-	go func() {
-		for watchResp := range watchChan {
-			if watchResp.Err() != nil {
-				m.log("subscribe").WithFields(logx.Field("key", key), logx.Field("error", watchResp.Err().Error())).Error("监听错误")
-				continue
+	var (
+		st      *stream.Stream
+		workers sync.WaitGroup // 回调协程，未启用队列时为空
+	)
+	if async {
+		size := options.QueueSize
+		if size <= 0 {
+			size = core.DefaultWorkerQueueSize
+		}
+		pool := dispatch.NewPool(&dispatch.Config{
+			Size:         size,
+			Policy:       options.QueuePolicy,
+			Prefix:       key,
+			Subscription: target.id,
+			Metrics:      m.config.Metrics,
+			Merge:        mergeEvents,
+			OnDiscard: func(value any) {
+				st.Done(ctx, value.(*core.WatchEvent), nil)
+			},
+		}, options.Workers)
+		sub.SetStats(pool.Stats)
+		st = stream.New(m.backend, m.logCtx, streamConfig, func(event *core.WatchEvent) error {
+			if !pool.Push(ctx, event.Key, event) {
+				return ctx.Err()
 			}
+			return nil
+		})
 
-			for _, ev := range watchResp.Events {
-				event := &core.WatchEvent{
-					Key: string(ev.Kv.Key),
-				}
-
-				switch ev.Type {
-				case clientv3.EventTypePut:
-					event.Value = ev.Kv.Value
-					event.EventType = core.EventTypePut
-				case clientv3.EventTypeDelete:
-					event.Value = nil
-					event.EventType = core.EventTypeDelete
-				}
-
-				if err := callback(event); err != nil {
-					m.log("subscribe").WithFields(logx.Field("key", event.Key), logx.Field("event_type", event.EventType), logx.Field("error", err.Error())).Error("处理事件失败")
+		// 回调协程先于初始同步启动，避免 OverflowBlock 时初始同步填满队列后阻塞
+		for i := range pool.Len() {
+			queue := pool.Queue(i)
+			workers.Add(1)
+			started := m.group.Go(func(context.Context) {
+				defer workers.Done()
+				for {
+					value, ok := queue.Pop(ctx)
+					if !ok || ctx.Err() != nil {
+						return
+					}
+					event := value.(*core.WatchEvent)
+					st.Done(ctx, event, handle(event))
 				}
+			})
+			if !started {
+				workers.Done()
+				cancel()
+				workers.Wait()
+				m.unregister(target)
+				return nil, core.ErrConnectionClosed
 			}
 		}
-	}()
+	} else {
+		st = stream.New(m.backend, m.logCtx, streamConfig, handle)
+	}
+	target.stream = st
 
-	m.log("subscribe").WithFields(logx.Field("key", key)).Info("订阅成功")
+	// 获取并处理当前值，存在检查点时改为从检查点续接
+	if err := st.Init(ctx); err != nil {
+		cancel()
+		m.unregister(target)
+		return nil, err
+	}
 
-	return nil
+	// 从快照的下一个版本开始监听，版本被压缩时自动重新同步，通道关闭时退避重连
+	started := m.group.Go(func(context.Context) {
+		defer func() {
+			cancel()
+			workers.Wait()
+			m.unregister(target)
+			sub.Finish(m.finishReason())
+		}()
+
+		_ = st.Run(ctx)
+	})
+	if !started {
+		cancel()
+		m.unregister(target)
+		sub.Finish(core.ErrConnectionClosed)
+		return sub, nil
+	}
+
+	m.log("subscribe").WithFields(core.Field("key", key), core.Field("subscription", target.id)).Info("订阅成功")
+
+	return sub, nil
 }
 
 // WatchPut 写入原始数据
 func (m *watcherManager) WatchPut(key string, value []byte) error {
-	if m.client == nil {
+	if m.backend == nil || m.group.Closed() {
 		return core.ErrConnectionClosed
 	}
 
@@ -102,7 +196,11 @@ func (m *watcherManager) WatchPut(key string, value []byte) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := m.client.Put(ctx, key, string(value))
+	ctx, span := m.config.Tracer.Start(ctx, "etcdtrigger.watch_put", trace.SpanKindProducer, key)
+	start := time.Now()
+	_, err := m.config.Tracer.Put(ctx, m.backend, key, value)
+	m.config.Metrics.Write(metrics.OpWatchPut, time.Since(start), err)
+	tracing.End(span, err)
 	if err != nil {
 		return fmt.Errorf("%w: %v", core.ErrPutFailed, err)
 	}
@@ -112,7 +210,7 @@ func (m *watcherManager) WatchPut(key string, value []byte) error {
 
 // WatchDelete 删除数据
 func (m *watcherManager) WatchDelete(key string) error {
-	if m.client == nil {
+	if m.backend == nil || m.group.Closed() {
 		return core.ErrConnectionClosed
 	}
 
@@ -123,7 +221,11 @@ func (m *watcherManager) WatchDelete(key string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := m.client.Delete(ctx, key)
+	ctx, span := m.config.Tracer.Start(ctx, "etcdtrigger.watch_delete", trace.SpanKindProducer, key)
+	start := time.Now()
+	_, err := m.config.Tracer.Delete(ctx, m.backend, key)
+	m.config.Metrics.Write(metrics.OpWatchDelete, time.Since(start), err)
+	tracing.End(span, err)
 	if err != nil {
 		return fmt.Errorf("%w: %v", core.ErrDeleteFailed, err)
 	}
@@ -133,14 +235,14 @@ func (m *watcherManager) WatchDelete(key string) error {
 
 // WatchGet 获取原始数据
 func (m *watcherManager) WatchGet(key string) ([]byte, error) {
-	if m.client == nil {
+	if m.backend == nil || m.group.Closed() {
 		return nil, core.ErrConnectionClosed
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	resp, err := m.client.Get(ctx, key)
+	resp, err := m.backend.Get(ctx, key, false)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", core.ErrGetFailed, err)
 	}
@@ -152,7 +254,69 @@ func (m *watcherManager) WatchGet(key string) ([]byte, error) {
 	return resp.Kvs[0].Value, nil
 }
 
+// Close 关闭监听管理器
+func (m *watcherManager) Close(ctx context.Context) error {
+	if err := m.group.Close(ctx); err != nil {
+		m.log("close").WithFields(core.Field("error", err.Error())).Error("等待回调结束超时")
+		return err
+	}
+
+	m.log("close").Info("关闭成功")
+	return nil
+}
+
+// invoke 执行一次订阅回调并记录耗时、错误与 panic
+func (m *watcherManager) invoke(s *subscriber, event *core.WatchEvent) error {
+	start := time.Now()
+	err := recovery.Call(event.Key, func() error {
+		return s.callback(event)
+	})
+	m.config.Metrics.Callback(s.key, s.id, time.Since(start), err)
+	if errors.Is(err, core.ErrCallbackPanic) {
+		m.reportPanic(s, err)
+	}
+	return err
+}
+
+// mergeEvents 合并同一键排队的事件，保留最早事件的变更前的值
+func mergeEvents(pending, latest any) any {
+	merged := *latest.(*core.WatchEvent)
+	merged.PrevValue = pending.(*core.WatchEvent).PrevValue
+	return &merged
+}
+
+// reportPanic 记录回调 panic、计数并通知错误回调
+func (m *watcherManager) reportPanic(s *subscriber, err error) {
+	var panicErr *core.PanicError
+	if !errors.As(err, &panicErr) {
+		return
+	}
+
+	m.log("callback").WithFields(core.Field("prefix", s.key), core.Field("subscription", s.id), core.Field("key", panicErr.Key), core.Field("panic", fmt.Sprint(panicErr.Value)), core.Field("stack", string(panicErr.Stack))).Error("回调 panic，已恢复")
+	m.config.Metrics.Panic(s.key, s.id)
+	m.notify(s.key, panicErr)
+}
+
+// notify 通知错误回调，错误回调自身的 panic 被恢复并忽略
+func (m *watcherManager) notify(prefix string, err error) {
+	if m.config.OnError == nil {
+		return
+	}
+	_ = recovery.Call(prefix, func() error {
+		m.config.OnError(prefix, err)
+		return nil
+	})
+}
+
+// finishReason 判断监听结束的原因
+func (m *watcherManager) finishReason() error {
+	if m.group.Closed() {
+		return core.ErrConnectionClosed
+	}
+	return core.ErrWatchCanceled
+}
+
 // log 创建结构化日志
-func (m *watcherManager) log(operation string) logx.Logger {
+func (m *watcherManager) log(operation string) *core.LogEntry {
 	return m.logCtx.WithModule("watcher", operation)
 }
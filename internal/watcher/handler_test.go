@@ -0,0 +1,276 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/checkpoint"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/testutil"
+)
+
+func TestCheckpointName(t *testing.T) {
+	ctx := context.Background()
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem)
+	checkpoints := checkpoint.NewBackendStore(mem, "/checkpoints/")
+
+	// 第二个订阅的回调失败，两个订阅共用存储但检查点互不覆盖
+	ok := func(*core.WatchEvent) error { return nil }
+	h := &flakyHandler{failing: map[string]bool{"/jobs/a": true}}
+	if _, err := m.Watch("/jobs/", ok, core.WithCheckpoint(checkpoints)); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if _, err := m.Watch("/jobs/", h.handle, core.WithCheckpoint(checkpoints), core.WithCheckpointName("/jobs/#audit")); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	a, _ := mem.Put(ctx, "/jobs/a", []byte("1"), 0)
+	load := func(name string) int64 {
+		revision, _ := checkpoints.Load(ctx, name)
+		return revision
+	}
+	testutil.Eventually(t, func() bool { return load("/jobs/") == a }, "默认名称的检查点未推进到 %d", a)
+	if got := load("/jobs/#audit"); got >= a {
+		t.Fatalf("处理失败的订阅检查点 = %d, want 早于 %d", got, a)
+	}
+}
+
+func TestWatchEventFields(t *testing.T) {
+	ctx := context.Background()
+	mem := backend.NewMemory()
+	m := newTestManager(t, mem)
+
+	events := make(chan *core.WatchEvent, 8)
+	_, err := m.Watch("/jobs/", func(event *core.WatchEvent) error {
+		events <- event
+		return nil
+	}, core.WithPrevValue())
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	next := func() *core.WatchEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(testutil.Timeout):
+			t.Fatal("等待事件超时")
+			return nil
+		}
+	}
+
+	lease, err := mem.Grant(ctx, 60)
+	if err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+	created, _ := mem.Put(ctx, "/jobs/a", []byte("1"), lease)
+	modified, _ := mem.Put(ctx, "/jobs/a", []byte("2"), 0)
+	deleted, _ := mem.Delete(ctx, "/jobs/a", false)
+
+	tests := []struct {
+		name  string
+		want  core.WatchEvent
+		isNew bool
+		isMod bool
+	}{
+		{
+			name:  "create",
+			want:  core.WatchEvent{Key: "/jobs/a", Value: []byte("1"), EventType: core.EventTypePut, Revision: created, CreateRevision: created, ModRevision: created, Version: 1, Lease: lease},
+			isNew: true,
+		},
+		{
+			name:  "modify",
+			want:  core.WatchEvent{Key: "/jobs/a", Value: []byte("2"), EventType: core.EventTypePut, Revision: modified, CreateRevision: created, ModRevision: modified, Version: 2, PrevValue: []byte("1")},
+			isMod: true,
+		},
+		{
+			name: "delete",
+			want: core.WatchEvent{Key: "/jobs/a", EventType: core.EventTypeDelete, Revision: deleted, ModRevision: deleted, PrevValue: []byte("2")},
+		},
+	}
+	for _, tt := range tests {
+		got := next()
+		if got.Key != tt.want.Key || string(got.Value) != string(tt.want.Value) || got.EventType != tt.want.EventType {
+			t.Fatalf("%s: 事件 = %s %s %q, want %s %s %q", tt.name, got.EventType, got.Key, got.Value, tt.want.EventType, tt.want.Key, tt.want.Value)
+		}
+		if got.Revision != tt.want.Revision || got.CreateRevision != tt.want.CreateRevision || got.ModRevision != tt.want.ModRevision || got.Version != tt.want.Version || got.Lease != tt.want.Lease {
+			t.Fatalf("%s: 版本 = rev %d create %d mod %d version %d lease %d, want rev %d create %d mod %d version %d lease %d", tt.name,
+				got.Revision, got.CreateRevision, got.ModRevision, got.Version, got.Lease,
+				tt.want.Revision, tt.want.CreateRevision, tt.want.ModRevision, tt.want.Version, tt.want.Lease)
+		}
+		if string(got.PrevValue) != string(tt.want.PrevValue) {
+			t.Fatalf("%s: PrevValue = %q, want %q", tt.name, got.PrevValue, tt.want.PrevValue)
+		}
+		if got.IsCreate() != tt.isNew || got.IsModify() != tt.isMod {
+			t.Fatalf("%s: IsCreate() = %v, IsModify() = %v, want %v, %v", tt.name, got.IsCreate(), got.IsModify(), tt.isNew, tt.isMod)
+		}
+	}
+}
+
+func TestCallbackPanicIsolated(t *testing.T) {
+	ctx := context.Background()
+	mem := backend.NewMemory()
+	panics := make(chan error, 8)
+	m := newManager(mem, &core.LogContext{}, &Config{
+		OnError: func(prefix string, err error) {
+			if prefix == "/jobs/" {
+				panics <- err
+			}
+		},
+	})
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.Timeout)
+		defer cancel()
+		_ = m.Close(ctx)
+	})
+
+	// 第一个订阅在 /jobs/a 上 panic，之后的事件与另一个订阅不受影响
+	first := make(chan string, 8)
+	second := make(chan string, 8)
+	_, err := m.Watch("/jobs/", func(event *core.WatchEvent) error {
+		if event.Key == "/jobs/a" {
+			panic("callback panic")
+		}
+		first <- event.Key
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if _, err := m.Watch("/jobs/", func(event *core.WatchEvent) error {
+		second <- event.Key
+		return nil
+	}); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	_, _ = mem.Put(ctx, "/jobs/a", []byte("1"), 0)
+	_, _ = mem.Put(ctx, "/jobs/b", []byte("1"), 0)
+
+	receive := func(ch <-chan string, want string) {
+		t.Helper()
+		select {
+		case key := <-ch:
+			if key != want {
+				t.Fatalf("收到 %s, want %s", key, want)
+			}
+		case <-time.After(testutil.Timeout):
+			t.Fatalf("未收到 %s", want)
+		}
+	}
+	receive(first, "/jobs/b")
+	receive(second, "/jobs/a")
+	receive(second, "/jobs/b")
+
+	select {
+	case err := <-panics:
+		var panicErr *core.PanicError
+		if !errors.As(err, &panicErr) || panicErr.Key != "/jobs/a" || panicErr.Value != "callback panic" || len(panicErr.Stack) == 0 {
+			t.Fatalf("OnError = %v, want /jobs/a 的 PanicError 且包含调用栈", err)
+		}
+	case <-time.After(testutil.Timeout):
+		t.Fatal("OnError 未收到 panic")
+	}
+}
+
+func TestWatchWorkers(t *testing.T) {
+	ctx := context.Background()
+	mem := backend.NewMemory()
+	errs := make(chan error, 8)
+	m := newManager(mem, &core.LogContext{}, &Config{
+		OnError: func(_ string, err error) { errs <- err },
+	})
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.Timeout)
+		defer cancel()
+		_ = m.Close(ctx)
+	})
+
+	var (
+		mu        sync.Mutex
+		inflight  int
+		revisions = make(map[string][]int64)
+		release   = make(chan struct{})
+	)
+	_, err := m.Watch("/jobs/", func(event *core.WatchEvent) error {
+		mu.Lock()
+		inflight++
+		revisions[event.Key] = append(revisions[event.Key], event.Revision)
+		mu.Unlock()
+
+		// 首批回调阻塞，直到多个键的回调同时执行
+		<-release
+
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+		if string(event.Value) == "fail" {
+			return errors.New("处理失败")
+		}
+		return nil
+	}, core.WithWorkers(4))
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	keys := []string{"/jobs/a", "/jobs/b", "/jobs/c", "/jobs/d", "/jobs/e", "/jobs/f", "/jobs/g", "/jobs/h"}
+	const rounds = 5
+	var failed int64
+	for round := range rounds {
+		for _, key := range keys {
+			value := []byte("ok")
+			if key == "/jobs/c" && round == 2 {
+				value = []byte("fail")
+			}
+			revision, err := mem.Put(ctx, key, value, 0)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if string(value) == "fail" {
+				failed = revision
+			}
+		}
+	}
+
+	testutil.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return inflight >= 2
+	}, "不同键的回调没有并发执行")
+	close(release)
+
+	testutil.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, key := range keys {
+			if len(revisions[key]) != rounds {
+				return false
+			}
+		}
+		return true
+	}, "未处理完全部事件")
+
+	mu.Lock()
+	for _, key := range keys {
+		if !slices.IsSorted(revisions[key]) {
+			t.Errorf("%s 的事件版本 = %v, want 递增", key, revisions[key])
+		}
+	}
+	mu.Unlock()
+
+	select {
+	case err := <-errs:
+		var callbackErr *core.CallbackError
+		if !errors.As(err, &callbackErr) || callbackErr.Key != "/jobs/c" || callbackErr.Revision != failed {
+			t.Fatalf("OnError = %v, want /jobs/c@%d 的 CallbackError", err, failed)
+		}
+	case <-time.After(testutil.Timeout):
+		t.Fatal("OnError 未收到回调错误")
+	}
+}
@@ -1,19 +1,24 @@
 package watcher
 
 import (
+	"context"
+
 	"github.com/rezeropoint/etcdtrigger/v2/core"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 // Manager 原始监听管理器接口
 type Manager interface {
-	Watch(key string, callback core.WatchCallback) error // 订阅配置变更
-	WatchPut(key string, value []byte) error             // 写入原始数据
-	WatchDelete(key string) error                        // 删除数据
-	WatchGet(key string) ([]byte, error)                 // 获取原始数据
+	Watch(key string, callback core.WatchCallback, opts ...core.WatchOption) (core.Subscription, error) // 订阅配置变更
+	WatchPut(key string, value []byte) error                                                            // 写入原始数据
+	WatchDelete(key string) error                                                                       // 删除数据
+	WatchGet(key string) ([]byte, error)                                                                // 获取原始数据
+	ListDeadLetters(ctx context.Context, prefix string) ([]*core.DeadLetter, error)                     // 列出死信
+	RedriveDeadLetters(ctx context.Context, prefix string, ids ...string) (int, error)                  // 重放死信
+	PurgeDeadLetters(ctx context.Context, prefix string, ids ...string) (int, error)                    // 删除死信
+	Close(ctx context.Context) error                                                                    // 关闭并等待回调结束
 }
 
 // NewManager 创建监听管理器
-func NewManager(client *clientv3.Client, logCtx *core.LogContext, config *Config) Manager {
-	return newManager(client, logCtx, config)
+func NewManager(backend core.Backend, logCtx *core.LogContext, config *Config) Manager {
+	return newManager(backend, logCtx, config)
 }
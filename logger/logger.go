@@ -0,0 +1,35 @@
+// Package logger 提供 core.Logger 的内置适配器。
+//
+//   - Logx: go-zero logx，engine.Config.Logger 为空时的默认实现
+//   - Slog: 标准库 log/slog
+//   - Zap: go.uber.org/zap
+//
+// 通过 engine.Config 选择日志输出与级别，模块级别优先于默认级别：
+//
+//	eng, err := engine.New(ctx, client, &engine.Config{
+//	    Logger:    logger.NewSlog(slog.Default()),
+//	    LogLevel:  "info",
+//	    LogLevels: map[string]string{"store": "error", "stream": "debug"},
+//	})
+//
+// slog 与 zap 适配器在 ctx 携带有效 Span 时附带 trace 与 span 字段，与 logx 一致。
+package logger
+
+import (
+	"context"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceFields 返回 ctx 中 Span 的 trace 与 span 字段，不携带有效 Span 时为 nil
+func traceFields(ctx context.Context) []core.LogField {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return nil
+	}
+	return []core.LogField{
+		core.Field("trace", spanCtx.TraceID().String()),
+		core.Field("span", spanCtx.SpanID().String()),
+	}
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var (
+	traceID = trace.TraceID{1}
+	spanID  = trace.SpanID{2}
+)
+
+// spanContext 携带有效 Span 的上下文
+func spanContext() context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+func TestTraceFields(t *testing.T) {
+	if fields := traceFields(context.Background()); fields != nil {
+		t.Fatalf("无 Span 时 traceFields = %v, want nil", fields)
+	}
+
+	fields := traceFields(spanContext())
+	if len(fields) != 2 || fields[0].Value != traceID.String() || fields[1].Value != spanID.String() {
+		t.Fatalf("traceFields = %v", fields)
+	}
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	log.Log(context.Background(), core.LevelDebug, "debug")
+	if buf.Len() != 0 {
+		t.Fatalf("低于 slog 级别的日志被输出: %s", buf.String())
+	}
+
+	log.Log(spanContext(), core.LevelError, "failed", core.Field("key", "/app/db"))
+	out := buf.String()
+	for _, want := range []string{"level=ERROR", "msg=failed", "key=/app/db", "trace=" + traceID.String(), "span=" + spanID.String()} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("slog 输出 %q 缺少 %q", out, want)
+		}
+	}
+}
+
+func TestZap(t *testing.T) {
+	zapCore, logs := observer.New(zapcore.InfoLevel)
+	log := NewZap(zap.New(zapCore))
+
+	log.Log(context.Background(), core.LevelDebug, "debug")
+	log.Log(context.Background(), core.LevelInfo, "started", core.Field("prefix", "/app/"))
+	log.Log(spanContext(), core.LevelError, "failed")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("zap 日志 %d 条, want 2", len(entries))
+	}
+	if entries[0].Message != "started" || entries[0].ContextMap()["prefix"] != "/app/" {
+		t.Fatalf("zap 日志 = %+v", entries[0])
+	}
+	if entries[1].Level != zapcore.ErrorLevel || entries[1].ContextMap()["trace"] != traceID.String() {
+		t.Fatalf("zap 日志 = %+v", entries[1])
+	}
+}
+
+func TestNilLogger(t *testing.T) {
+	if NewSlog(nil) == nil || NewZap(nil) == nil || NewLogx() == nil {
+		t.Fatal("适配器不应为 nil")
+	}
+}
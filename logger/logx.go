@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// logxLogger go-zero logx 适配器
+type logxLogger struct{}
+
+// NewLogx 创建输出到 go-zero logx 的日志
+// 说明：
+//   - Debug 日志同时受 logx 自身级别限制，需 logx.SetLevel(logx.DebugLevel) 或 Log.Level 为 debug
+func NewLogx() core.Logger {
+	return logxLogger{}
+}
+
+// Log 输出日志
+func (logxLogger) Log(ctx context.Context, level core.Level, msg string, fields ...core.LogField) {
+	logFields := make([]logx.LogField, 0, len(fields))
+	for _, field := range fields {
+		logFields = append(logFields, logx.Field(field.Key, field.Value))
+	}
+
+	logger := logx.WithContext(ctx).WithCallerSkip(core.LogCallerSkip + 1).WithFields(logFields...)
+	switch level {
+	case core.LevelDebug:
+		logger.Debug(msg)
+	case core.LevelError:
+		logger.Error(msg)
+	default:
+		logger.Info(msg)
+	}
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+)
+
+// slogLogger log/slog 适配器
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlog 创建输出到 log/slog 的日志
+// 参数：
+//   - logger: 目标 slog 日志，为 nil 时使用 slog.Default()
+func NewSlog(logger *slog.Logger) core.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return slogLogger{logger: logger}
+}
+
+// Log 输出日志
+func (l slogLogger) Log(ctx context.Context, level core.Level, msg string, fields ...core.LogField) {
+	slogLevel := toSlogLevel(level)
+	if !l.logger.Enabled(ctx, slogLevel) {
+		return
+	}
+
+	// 跳过 runtime.Callers、Log 与引擎内部的调用层，记录引擎中的调用点
+	var pcs [1]uintptr
+	runtime.Callers(core.LogCallerSkip+2, pcs[:])
+
+	record := slog.NewRecord(time.Now(), slogLevel, msg, pcs[0])
+	for _, field := range fields {
+		record.AddAttrs(slog.Any(field.Key, field.Value))
+	}
+	for _, field := range traceFields(ctx) {
+		record.AddAttrs(slog.Any(field.Key, field.Value))
+	}
+	_ = l.logger.Handler().Handle(ctx, record)
+}
+
+// toSlogLevel 转换为 slog 级别
+func toSlogLevel(level core.Level) slog.Level {
+	switch level {
+	case core.LevelDebug:
+		return slog.LevelDebug
+	case core.LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
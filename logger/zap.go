@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// zapLogger zap 适配器
+type zapLogger struct {
+	logger *zap.Logger
+}
+
+// NewZap 创建输出到 zap 的日志
+// 参数：
+//   - logger: 目标 zap 日志，为 nil 时使用 zap.L()
+func NewZap(logger *zap.Logger) core.Logger {
+	if logger == nil {
+		logger = zap.L()
+	}
+	return zapLogger{logger: logger.WithOptions(zap.AddCallerSkip(core.LogCallerSkip + 1))}
+}
+
+// Log 输出日志
+func (l zapLogger) Log(ctx context.Context, level core.Level, msg string, fields ...core.LogField) {
+	entry := l.logger.Check(toZapLevel(level), msg)
+	if entry == nil {
+		return
+	}
+
+	zapFields := make([]zap.Field, 0, len(fields)+2)
+	for _, field := range fields {
+		zapFields = append(zapFields, zap.Any(field.Key, field.Value))
+	}
+	for _, field := range traceFields(ctx) {
+		zapFields = append(zapFields, zap.Any(field.Key, field.Value))
+	}
+	entry.Write(zapFields...)
+}
+
+// toZapLevel 转换为 zap 级别
+func toZapLevel(level core.Level) zapcore.Level {
+	switch level {
+	case core.LevelDebug:
+		return zapcore.DebugLevel
+	case core.LevelError:
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
@@ -0,0 +1,119 @@
+// Package store 提供基于泛型的强类型配置访问。
+//
+// 相比 Engine.GetConfig 的反射接口，类型参数在编译期确定，
+// 调用方直接拿到值，不再需要传入指针：
+//
+//	eng := engine.NewEngine(etcdClient, &engine.Config{
+//	    Configs: []core.WatchConfig{
+//	        store.Bind[DatabaseConfig]("/app/config/database/"),
+//	    },
+//	})
+//
+//	// 写入
+//	err := store.Put(ctx, eng, "/app/config/database/main", DatabaseConfig{Host: "localhost"})
+//
+//	// 从缓存读取
+//	db, ok := store.Get[DatabaseConfig](eng, "/app/config/database/main")
+//
+//	// 订阅变更，old 为 nil 表示新增，new 为 nil 表示删除
+//	sub := store.Subscribe(eng, "/app/config/database/", func(key string, old, new *DatabaseConfig) {})
+//	defer sub.Unsubscribe()
+package store
+
+import (
+	"context"
+
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/engine"
+)
+
+// ChangeCallback 强类型配置变更回调
+// 参数：
+//   - key: 变更的配置键
+//   - old: 变更前的配置，新增时为 nil
+//   - new: 变更后的配置，删除时为 nil
+type ChangeCallback[T any] func(key string, old, new *T)
+
+// Bind 创建绑定类型 T 的预加载配置项
+// 参数：
+//   - path: 监听路径（支持前缀）
+//
+// 返回：
+//   - core.WatchConfig: 用于 engine.Config.Configs
+//
+// 说明：
+//   - T 必须是结构体类型
+func Bind[T any](path string) core.WatchConfig {
+	return core.WatchConfig{
+		Path:   path,
+		Struct: new(T),
+	}
+}
+
+// Get 从内存缓存获取类型为 T 的配置
+// 参数：
+//   - eng: 配置管理引擎
+//   - key: 配置键名
+//
+// 返回：
+//   - T: 配置值，不存在时为零值
+//   - bool: true 表示获取成功，false 表示配置不存在或 T 未通过 Configs 预加载
+func Get[T any](eng engine.Engine, key string) (T, bool) {
+	var value T
+	ok := eng.GetConfig(key, &value)
+	return value, ok
+}
+
+// Put 写入类型为 T 的配置到 etcd
+// 参数：
+//   - ctx: 上下文
+//   - eng: 配置管理引擎
+//   - key: 配置键名
+//   - value: 配置值
+//
+// 返回：
+//   - error: 校验、序列化或写入失败时返回错误
+func Put[T any](ctx context.Context, eng engine.Engine, key string, value T) error {
+	return eng.PutConfig(ctx, key, &value)
+}
+
+// Subscribe 订阅前缀下类型为 T 的配置变更
+// 参数：
+//   - eng: 配置管理引擎
+//   - prefix: 要监听的键前缀
+//   - callback: 变更回调，携带变更前后的配置
+//   - opts: 订阅选项，仅 core.WithQueue 适用
+//
+// 返回：
+//   - core.Subscription: 订阅句柄
+//
+// 说明：
+//   - 添加时会立即以 old 为 nil 触发已存在的配置
+//   - 前缀下其他类型的配置会被忽略
+//   - 反序列化或校验失败被拒绝的值不会触发回调，需要观察时使用 Engine.AddConfigWatcher
+//   - old 与 new 为缓存实例的浅拷贝，可以修改顶层字段；切片、映射与指针字段仍与缓存共享，不要修改其内容
+func Subscribe[T any](eng engine.Engine, prefix string, callback ChangeCallback[T], opts ...core.WatchOption) core.Subscription {
+	return eng.AddConfigWatcher(prefix, func(change *core.ConfigChange) {
+		if change.EventType.IsReject() {
+			return
+		}
+
+		old, oldOK := change.Old.(*T)
+		value, newOK := change.New.(*T)
+		if !oldOK && !newOK {
+			return
+		}
+
+		callback(change.Key, clone(old), clone(value))
+	}, opts...)
+}
+
+// clone 浅拷贝缓存实例，nil 保持为 nil
+func clone[T any](instance *T) *T {
+	if instance == nil {
+		return nil
+	}
+
+	value := *instance
+	return &value
+}
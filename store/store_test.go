@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rezeropoint/etcdtrigger/v2/backend"
+	"github.com/rezeropoint/etcdtrigger/v2/core"
+	"github.com/rezeropoint/etcdtrigger/v2/engine"
+	"github.com/rezeropoint/etcdtrigger/v2/internal/testutil"
+)
+
+type databaseConfig struct {
+	Host string   `json:"host"`
+	Tags []string `json:"tags"`
+}
+
+type otherConfig struct {
+	Name string `json:"name"`
+}
+
+// change 强类型回调收到的变更
+type change struct {
+	key      string
+	old, new *databaseConfig
+}
+
+// newTestEngine 创建基于内存后端的引擎，测试结束时关闭
+func newTestEngine(t *testing.T) engine.Engine {
+	t.Helper()
+
+	eng, err := engine.NewWithBackend(context.Background(), backend.NewMemory(), &engine.Config{
+		Configs: []core.WatchConfig{
+			Bind[databaseConfig]("/app/database/"),
+			Bind[otherConfig]("/app/other/"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewWithBackend: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.Timeout)
+		defer cancel()
+		if err := eng.Close(ctx); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+	return eng
+}
+
+// nextChange 读取下一个变更，超时终止测试
+func nextChange(t *testing.T, ch <-chan change) change {
+	t.Helper()
+
+	select {
+	case c := <-ch:
+		return c
+	case <-time.After(testutil.Timeout):
+		t.Fatal("未收到配置变更")
+		return change{}
+	}
+}
+
+func TestBind(t *testing.T) {
+	config := Bind[databaseConfig]("/app/database/")
+	if _, ok := config.Struct.(*databaseConfig); !ok || config.Path != "/app/database/" {
+		t.Fatalf("Bind = %+v", config)
+	}
+}
+
+func TestGetPutSubscribe(t *testing.T) {
+	ctx := context.Background()
+	eng := newTestEngine(t)
+
+	if _, ok := Get[databaseConfig](eng, "/app/database/main"); ok {
+		t.Fatal("写入前 Get 应返回 false")
+	}
+
+	ch := make(chan change, 10)
+	sub := Subscribe(eng, "/app/", func(key string, old, new *databaseConfig) {
+		ch <- change{key: key, old: old, new: new}
+	})
+	defer sub.Unsubscribe()
+
+	if err := Put(ctx, eng, "/app/database/main", databaseConfig{Host: "a", Tags: []string{"x"}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if c := nextChange(t, ch); c.key != "/app/database/main" || c.old != nil || c.new.Host != "a" {
+		t.Fatalf("新增 = %+v", c)
+	}
+
+	// 前缀下其他类型的配置被忽略
+	if err := Put(ctx, eng, "/app/other/main", otherConfig{Name: "n"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := Put(ctx, eng, "/app/database/main", databaseConfig{Host: "b"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	c := nextChange(t, ch)
+	if c.old == nil || c.old.Host != "a" || c.new.Host != "b" {
+		t.Fatalf("更新 = %+v", c)
+	}
+
+	// 修改回调收到的顶层字段不影响缓存
+	c.new.Host = "changed"
+	if got, ok := Get[databaseConfig](eng, "/app/database/main"); !ok || got.Host != "b" {
+		t.Fatalf("Get = %+v, %v, want Host b", got, ok)
+	}
+	if _, ok := Get[otherConfig](eng, "/app/database/main"); ok {
+		t.Fatal("类型不匹配时 Get 应返回 false")
+	}
+
+	if _, err := eng.Backend().Delete(ctx, "/app/database/main", false); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if c := nextChange(t, ch); c.old.Host != "b" || c.new != nil {
+		t.Fatalf("删除 = %+v", c)
+	}
+}